@@ -3,18 +3,40 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
 	"runtime/debug"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/urfave/cli/v2"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/compare"
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/hook"
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/schedule"
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/srv"
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/ssh"
 )
 
 const (
 	defaultSSHPort = 22
+
+	backupFileMode = 0o600
+	backupDirMode  = 0o750
+
+	defaultInterval  = 24 * time.Hour
+	defaultJitter    = 15 * time.Minute
+	defaultRetryBase = time.Minute
 )
 
 func main() {
@@ -34,6 +56,8 @@ to local files for version control and disaster recovery.`,
 		},
 		Commands: []*cli.Command{
 			backupCommand(),
+			daemonCommand(),
+			compareDevicesCommand(),
 			versionCommand(),
 		},
 		EnableBashCompletion: true,
@@ -53,65 +77,658 @@ func backupCommand() *cli.Command {
 		Usage: "Backup MikroTik configuration",
 		Description: `Connect to a MikroTik device and backup its configuration.
 Supports both password and SSH key-based authentication.`,
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:     "host",
-				Aliases:  []string{"H"},
-				Usage:    "MikroTik device hostname or IP address",
-				Required: true,
-				EnvVars:  []string{"MIKROTIK_HOST"},
-			},
-			&cli.IntFlag{
-				Name:    "port",
-				Aliases: []string{"p"},
-				Usage:   "SSH port",
-				Value:   defaultSSHPort,
-				EnvVars: []string{"MIKROTIK_PORT"},
-			},
-			&cli.StringFlag{
-				Name:    "username",
-				Aliases: []string{"u"},
-				Usage:   "SSH username",
-				Value:   "admin",
-				EnvVars: []string{"MIKROTIK_USERNAME"},
+		Flags:  backupFlags(),
+		Action: runBackup,
+	}
+}
+
+func daemonCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "daemon",
+		Usage: "Back up MikroTik configurations on a schedule",
+		Description: `Keep running and back up every --host once per --interval. Each host
+runs at its own offset within --jitter, and failed backups are retried with
+exponential backoff. Schedules are saved to --state-file so a restart resumes
+them instead of backing up every device at once.`,
+		Flags: append(backupFlags(),
+			&cli.DurationFlag{
+				Name:    "interval",
+				Usage:   "Time between backups of each host",
+				Value:   defaultInterval,
+				EnvVars: []string{"MIKROTIK_INTERVAL"},
 			},
-			&cli.StringFlag{
-				Name:    "password",
-				Aliases: []string{"P"},
-				Usage:   "SSH password (use with caution, prefer SSH key)",
-				EnvVars: []string{"MIKROTIK_PASSWORD"},
+			&cli.DurationFlag{
+				Name:    "jitter",
+				Usage:   "Spread hosts over this window so they do not all run at once",
+				Value:   defaultJitter,
+				EnvVars: []string{"MIKROTIK_JITTER"},
 			},
-			&cli.StringFlag{
-				Name:    "key",
-				Aliases: []string{"k"},
-				Usage:   "Path to SSH private key file",
-				EnvVars: []string{"MIKROTIK_KEY_FILE"},
+			&cli.DurationFlag{
+				Name:    "retry-base",
+				Usage:   "First retry delay after a failed backup, doubled on each further failure up to --interval",
+				Value:   defaultRetryBase,
+				EnvVars: []string{"MIKROTIK_RETRY_BASE"},
 			},
 			&cli.StringFlag{
-				Name:    "output",
-				Aliases: []string{"o"},
-				Usage:   "Output file path for the backup",
-				Value:   "backup.rsc",
+				Name:    "state-file",
+				Usage:   "File recording each host's last and next run",
+				Value:   "mikrotik-backup-state.json",
+				EnvVars: []string{"MIKROTIK_STATE_FILE"},
 			},
+		),
+		Action: runDaemon,
+	}
+}
+
+// backupFlags returns the flags of commands that store device backups.
+func backupFlags() []cli.Flag {
+	return append(connectionFlags(),
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "Output file path for the backup",
+			Value:   "backup.rsc",
+		},
+		&cli.StringFlag{
+			Name:    "output-dir",
+			Usage:   "Directory for per-device backup files named after each host",
+			EnvVars: []string{"MIKROTIK_OUTPUT_DIR"},
+		},
+		&cli.StringFlag{
+			Name:    "output-owner",
+			Usage:   "Give written backups to user[:group] (requires root)",
+			EnvVars: []string{"MIKROTIK_OUTPUT_OWNER"},
+		},
+		&cli.BoolFlag{
+			Name:    "backup-name-from-identity",
+			Usage:   "Name backup files after the device's RouterOS identity",
+			EnvVars: []string{"MIKROTIK_BACKUP_NAME_FROM_IDENTITY"},
+		},
+		&cli.BoolFlag{
+			Name:    "include-change-time",
+			Usage:   "Capture device uptime and last configuration change time into metadata",
+			EnvVars: []string{"MIKROTIK_INCLUDE_CHANGE_TIME"},
+		},
+		&cli.BoolFlag{
+			Name:    "include-routes",
+			Usage:   "Capture the IPv4 routing table into <output>.routes.json",
+			EnvVars: []string{"MIKROTIK_INCLUDE_ROUTES"},
+		},
+		&cli.BoolFlag{
+			Name:    "include-ipv6-routes",
+			Usage:   "Also capture the IPv6 routing table (implies --include-routes)",
+			EnvVars: []string{"MIKROTIK_INCLUDE_IPV6_ROUTES"},
+		},
+		&cli.BoolFlag{
+			Name:    "include-dynamic-routes",
+			Usage:   "Keep dynamic and connected routes in the captured routing tables",
+			EnvVars: []string{"MIKROTIK_INCLUDE_DYNAMIC_ROUTES"},
+		},
+		&cli.BoolFlag{
+			Name:    "split-scripts",
+			Usage:   "Also write each /system script source to scripts/<backup>/<name>.rsc",
+			EnvVars: []string{"MIKROTIK_SPLIT_SCRIPTS"},
+		},
+		&cli.IntFlag{
+			Name:    "keep",
+			Usage:   "Timestamp backups and keep only the newest N per device (0 overwrites a single file)",
+			EnvVars: []string{"MIKROTIK_KEEP"},
+		},
+		&cli.StringFlag{
+			Name:    "per-device-hook",
+			Usage:   "Command run after each device completes, with metadata in MIKROTIK_* environment variables",
+			EnvVars: []string{"MIKROTIK_PER_DEVICE_HOOK"},
+		},
+		&cli.DurationFlag{
+			Name:    "per-device-hook-timeout",
+			Usage:   "Kill the --per-device-hook if it runs longer than this (0 disables)",
+			Value:   hook.DefaultTimeout,
+			EnvVars: []string{"MIKROTIK_PER_DEVICE_HOOK_TIMEOUT"},
+		},
+	)
+}
+
+// connectionFlags returns the flags shared by commands that connect to
+// devices and export their configuration.
+func connectionFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "host",
+			Aliases:  []string{"H"},
+			Usage:    "MikroTik device hostname or IP address (may be repeated)",
+			Required: true,
+			EnvVars:  []string{"MIKROTIK_HOST"},
+		},
+		&cli.BoolFlag{
+			Name:    "srv",
+			Usage:   "Treat each --host as a DNS SRV name (e.g. _mikrotik-ssh._tcp.example.com) listing devices",
+			EnvVars: []string{"MIKROTIK_SRV"},
+		},
+		&cli.IntFlag{
+			Name:    "port",
+			Aliases: []string{"p"},
+			Usage:   "SSH port",
+			Value:   defaultSSHPort,
+			EnvVars: []string{"MIKROTIK_PORT"},
+		},
+		&cli.StringFlag{
+			Name:    "username",
+			Aliases: []string{"u"},
+			Usage:   "SSH username",
+			Value:   "admin",
+			EnvVars: []string{"MIKROTIK_USERNAME"},
+		},
+		&cli.StringFlag{
+			Name:    "password",
+			Aliases: []string{"P"},
+			Usage:   "SSH password (use with caution, prefer SSH key)",
+			EnvVars: []string{"MIKROTIK_PASSWORD"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "key",
+			Aliases: []string{"k"},
+			Usage:   "Path to SSH private key file (may be repeated)",
+			EnvVars: []string{"MIKROTIK_KEY_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    "cert",
+			Usage:   "Path to SSH certificate for one of the --key files",
+			EnvVars: []string{"MIKROTIK_CERT_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    "auth-order",
+			Usage:   "Comma-separated authentication methods to try in order",
+			Value:   strings.Join(ssh.DefaultAuthOrder(), ","),
+			EnvVars: []string{"MIKROTIK_AUTH_ORDER"},
+		},
+		&cli.StringFlag{
+			Name:    "known-hosts",
+			Usage:   "Path to known_hosts file used to verify the device host key (default: ~/.ssh/known_hosts)",
+			EnvVars: []string{"MIKROTIK_KNOWN_HOSTS"},
+		},
+		&cli.BoolFlag{
+			Name:    "confirm-fingerprint",
+			Usage:   "Ask before trusting unknown host keys and add accepted ones to known_hosts (fails without a terminal)",
+			EnvVars: []string{"MIKROTIK_CONFIRM_FINGERPRINT"},
+		},
+		&cli.StringFlag{
+			Name:    "export-command",
+			Usage:   "Command used instead of " + backup.DefaultExportCommand + " to export the configuration",
+			EnvVars: []string{"MIKROTIK_EXPORT_COMMAND"},
+		},
+		&cli.BoolFlag{
+			Name:    "allow-write-commands",
+			Usage:   "Allow --export-command to run commands that are not read-only",
+			EnvVars: []string{"MIKROTIK_ALLOW_WRITE_COMMANDS"},
+		},
+		&cli.Int64Flag{
+			Name:    "max-output-size",
+			Usage:   "Abort a device whose command output exceeds this many bytes (0 for no limit)",
+			EnvVars: []string{"MIKROTIK_MAX_OUTPUT_SIZE"},
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Usage:   "Print connection details",
+			EnvVars: []string{"MIKROTIK_VERBOSE"},
 		},
-		Action: runBackup,
 	}
 }
 
 func runBackup(c *cli.Context) error {
-	// TODO: Implement backup logic
-	_, _ = fmt.Fprintf(c.App.Writer, "Backing up configuration from %s:%d\n", c.String("host"), c.Int("port"))
-	_, _ = fmt.Fprintf(c.App.Writer, "Username: %s\n", c.String("username"))
-	_, _ = fmt.Fprintf(c.App.Writer, "Output: %s\n", c.String("output"))
+	config, err := backupConfig(c)
+	if err != nil {
+		return err
+	}
 
-	// Validate authentication method
-	password := c.String("password")
-	keyFile := c.String("key")
-	if password == "" && keyFile == "" {
-		return errors.New("either --password or --key must be provided")
+	if err := validateAuth(config); err != nil {
+		return err
 	}
 
-	return errors.New("not implemented yet")
+	configs, err := deviceConfigs(c, config, c.StringSlice("host"))
+	if err != nil {
+		return err
+	}
+
+	owner, err := outputOwner(c.String("output-owner"))
+	if err != nil {
+		return err
+	}
+
+	batch, err := newBatch(c, len(configs), owner)
+	if err != nil {
+		return err
+	}
+
+	return batch.Run(c.Context, configs)
+}
+
+// newBatch prepares the batch storing backups as the backup flags of c
+// describe, giving written files to owner when it is not nil. hostCount is
+// the number of devices backed up per run, which decides whether --output
+// applies.
+func newBatch(c *cli.Context, hostCount int, owner *backup.Owner) (*backup.Batch, error) {
+	if c.Int("keep") < 0 {
+		return nil, errors.New("--keep must not be negative")
+	}
+
+	namer := backup.NewNamer()
+	pathFor, err := outputPathFunc(c, hostCount, namer)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := hostKeyCallback(c)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []backup.BatchOption{backup.WithDeviceCallback(deviceReporter(c))}
+	if c.Bool("backup-name-from-identity") {
+		// Name devices only once every identity of the batch is known.
+		opts = append(opts, backup.WithExportCallback(func(_ context.Context, result backup.DeviceResult) {
+			namer.Observe(result.Metadata.Identity, result.Config.Host)
+		}))
+	}
+
+	service := backup.New(ssh.NewClient(hostKeyCallback))
+	return backup.NewBatch(service, backupWriter(c, pathFor, owner), opts...), nil
+}
+
+func runDaemon(c *cli.Context) error {
+	interval, jitter := c.Duration("interval"), c.Duration("jitter")
+	if interval <= 0 {
+		return errors.New("--interval must be positive")
+	}
+	if jitter < 0 || jitter >= interval {
+		return errors.New("--jitter must not be negative and must be shorter than --interval")
+	}
+
+	config, err := backupConfig(c)
+	if err != nil {
+		return err
+	}
+	if err := validateAuth(config); err != nil {
+		return err
+	}
+
+	// With --srv the devices behind each name are only known when it runs,
+	// so backups are always named per device.
+	hosts := c.StringSlice("host")
+	hostCount := len(hosts)
+	if c.Bool("srv") {
+		hostCount = 0
+	}
+	owner, err := outputOwner(c.String("output-owner"))
+	if err != nil {
+		return err
+	}
+	batch, err := newBatch(c, hostCount, owner)
+	if err != nil {
+		return err
+	}
+
+	statePath := c.String("state-file")
+	state, err := schedule.Load(statePath)
+	if err != nil {
+		return err
+	}
+
+	scheduler := schedule.New(schedule.Config{
+		Interval:  interval,
+		Jitter:    jitter,
+		RetryBase: c.Duration("retry-base"),
+	}, state)
+	scheduler.Resume(hosts, time.Now())
+	if err := schedule.Save(statePath, scheduler.State(), owner); err != nil {
+		return err
+	}
+
+	for {
+		host, at, ok := scheduler.Next()
+		if !ok || !sleepUntil(c.Context, at) {
+			return nil
+		}
+
+		start := time.Now()
+		err := backupHost(c, config, batch, host)
+		if c.Context.Err() != nil {
+			// Interrupted: keep the schedule so the host runs again on restart.
+			return nil
+		}
+		if err != nil {
+			_, _ = fmt.Fprintf(c.App.ErrWriter, "Warning: %v\n", err)
+		}
+
+		scheduler.Done(host, start, err)
+		if err := schedule.Save(statePath, scheduler.State(), owner); err != nil {
+			return err
+		}
+		if c.Bool("verbose") {
+			_, next, _ := scheduler.Next()
+			_, _ = fmt.Fprintf(c.App.ErrWriter, "Next backup at %s\n", next.Format(time.RFC3339))
+		}
+	}
+}
+
+// backupHost runs one scheduled backup of the devices named by host.
+func backupHost(c *cli.Context, config backup.Config, batch *backup.Batch, host string) error {
+	configs, err := deviceConfigs(c, config, []string{host})
+	if err != nil {
+		return err
+	}
+	return batch.Run(c.Context, configs)
+}
+
+// sleepUntil waits until t and reports false if ctx ended first.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// deviceConfigs returns one configuration per device named by hosts. With
+// --srv, each host is an SRV name expanded into the targets it publishes.
+func deviceConfigs(c *cli.Context, config backup.Config, hosts []string) ([]backup.Config, error) {
+	var configs []backup.Config
+	for _, host := range hosts {
+		if !c.Bool("srv") {
+			config.Host = host
+			configs = append(configs, config)
+			continue
+		}
+
+		targets, err := srv.Lookup(c.Context, net.DefaultResolver, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, target := range targets {
+			config.Host = target.Host
+			config.Port = target.Port
+			configs = append(configs, config)
+		}
+	}
+	return configs, nil
+}
+
+// backupWriter returns the backup.WriteFunc storing each device backup at
+// the path chosen by pathFor, along with its metadata when requested. With
+// keep set, backups are timestamped and older ones rotated away. Written
+// files and created directories are given to owner when it is not nil.
+func backupWriter(c *cli.Context, pathFor func(backup.Metadata, string) string, owner *backup.Owner) backup.WriteFunc {
+	keep := c.Int("keep")
+
+	return func(_ context.Context, config backup.Config, meta backup.Metadata, data []byte) (string, error) {
+		outputPath := pathFor(meta, config.Host)
+		dir := filepath.Dir(outputPath)
+		base := strings.TrimSuffix(filepath.Base(outputPath), ".rsc")
+		if keep > 0 {
+			outputPath = filepath.Join(dir, backup.TimestampedName(base, time.Now()))
+		}
+
+		if err := backup.MkdirAll(dir, backupDirMode, owner); err != nil {
+			return "", err
+		}
+		if err := backup.WriteFileAtomic(outputPath, data, backupFileMode, owner); err != nil {
+			return "", fmt.Errorf("failed to write backup: %w", err)
+		}
+
+		if config.IncludeChangeTime {
+			if err := writeJSON(outputPath+backup.MetadataExt, meta, owner); err != nil {
+				return outputPath, err
+			}
+		}
+		if meta.Routes != nil {
+			if err := writeJSON(outputPath+backup.RoutesExt, meta.Routes, owner); err != nil {
+				return outputPath, err
+			}
+		}
+		if config.IncludeScripts {
+			scriptsDir := filepath.Join(dir, "scripts", base)
+			if err := backup.MkdirAll(scriptsDir, backupDirMode, owner); err != nil {
+				return outputPath, err
+			}
+			if _, err := backup.WriteScripts(scriptsDir, meta.Scripts, backupFileMode, owner); err != nil {
+				return outputPath, err
+			}
+		}
+
+		if keep > 0 {
+			removed, err := backup.Rotate(dir, base, filepath.Base(outputPath), keep)
+			if err != nil {
+				return outputPath, fmt.Errorf("failed to rotate backups: %w", err)
+			}
+			if c.Bool("verbose") {
+				for _, path := range removed {
+					_, _ = fmt.Fprintf(c.App.ErrWriter, "Removed old backup %s\n", path)
+				}
+			}
+		}
+
+		return outputPath, nil
+	}
+}
+
+// deviceReporter returns the callback reporting each completed device and
+// running the --per-device-hook, if any. Hook failures are only logged.
+func deviceReporter(c *cli.Context) backup.DeviceCallback {
+	var perDevice *hook.Command
+	if path := c.String("per-device-hook"); path != "" {
+		perDevice = hook.New(path, c.App.ErrWriter, hook.WithTimeout(c.Duration("per-device-hook-timeout")))
+	}
+
+	return func(ctx context.Context, result backup.DeviceResult) {
+		if result.Err == nil {
+			_, _ = fmt.Fprintf(c.App.Writer, "Backed up %s:%d to %s\n", result.Config.Host, result.Config.Port, result.Path)
+		}
+		if routes := result.Metadata.Routes; routes != nil && len(routes.Skipped) > 0 {
+			_, _ = fmt.Fprintf(c.App.ErrWriter, "Warning: %s: skipped %d routes that could not be parsed\n", result.Config.Host, len(routes.Skipped))
+		}
+		if c.Bool("verbose") && result.Metadata.AuthMethod != "" {
+			_, _ = fmt.Fprintf(c.App.ErrWriter, "Authenticated to %s with: %s\n", result.Config.Host, result.Metadata.AuthMethod)
+		}
+
+		if perDevice == nil {
+			return
+		}
+		if err := perDevice.Run(ctx, result); err != nil {
+			_, _ = fmt.Fprintf(c.App.ErrWriter, "Warning: %s: %v\n", result.Config.Host, err)
+		}
+	}
+}
+
+// outputPathFunc returns the function that picks the backup file for a
+// device. A single host writes to --output; several hosts, --output-dir or
+// --backup-name-from-identity write one file per device into a directory,
+// named by namer.
+func outputPathFunc(c *cli.Context, hostCount int, namer *backup.Namer) (func(backup.Metadata, string) string, error) {
+	outputDir := c.String("output-dir")
+
+	if outputDir == "" && !c.Bool("backup-name-from-identity") && hostCount == 1 {
+		output := c.String("output")
+		return func(backup.Metadata, string) string { return output }, nil
+	}
+
+	if c.IsSet("output") {
+		return nil, errors.New("--output cannot be used with multiple hosts, --output-dir or --backup-name-from-identity")
+	}
+	if outputDir == "" {
+		outputDir = "."
+	}
+
+	return func(meta backup.Metadata, host string) string {
+		return filepath.Join(outputDir, namer.Name(nameIdentity(c, meta), host)+".rsc")
+	}, nil
+}
+
+// nameIdentity returns the identity a device's backup is named after, or ""
+// to name it after its host.
+func nameIdentity(c *cli.Context, meta backup.Metadata) string {
+	if c.Bool("backup-name-from-identity") {
+		return meta.Identity
+	}
+	return ""
+}
+
+func backupConfig(c *cli.Context) (backup.Config, error) {
+	authOrder, err := ssh.ParseAuthOrder(c.String("auth-order"))
+	if err != nil {
+		return backup.Config{}, fmt.Errorf("invalid --auth-order: %w", err)
+	}
+	if c.Int64("max-output-size") < 0 {
+		return backup.Config{}, errors.New("--max-output-size must not be negative")
+	}
+
+	return backup.Config{
+		Port:               c.Int("port"),
+		Username:           c.String("username"),
+		Password:           c.String("password"),
+		KeyFiles:           c.StringSlice("key"),
+		CertFile:           c.String("cert"),
+		AuthOrder:          authOrder,
+		ExportCommand:      c.String("export-command"),
+		AllowWriteCommands: c.Bool("allow-write-commands"),
+		IncludeIdentity:    c.Bool("backup-name-from-identity"),
+		IncludeChangeTime:  c.Bool("include-change-time"),
+
+		IncludeRoutes:        c.Bool("include-routes") || c.Bool("include-ipv6-routes"),
+		IncludeIPv6Routes:    c.Bool("include-ipv6-routes"),
+		IncludeDynamicRoutes: c.Bool("include-dynamic-routes"),
+		IncludeScripts:       c.Bool("split-scripts"),
+		MaxOutputSize:        c.Int64("max-output-size"),
+	}, nil
+}
+
+func validateAuth(config backup.Config) error {
+	if config.Password == "" && len(config.KeyFiles) == 0 && os.Getenv("SSH_AUTH_SOCK") == "" {
+		return errors.New("either --password, --key or an SSH agent must be provided")
+	}
+	return nil
+}
+
+// hostKeyCallback verifies host keys against the configured known_hosts file.
+// With --confirm-fingerprint, unknown hosts are offered to the operator.
+func hostKeyCallback(c *cli.Context) (gossh.HostKeyCallback, error) {
+	path := c.String("known-hosts")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate home directory: %w", err)
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	if c.Bool("confirm-fingerprint") {
+		callback, err := ssh.ConfirmingHostKeyCallback(path, ssh.NewTerminalPrompt(os.Stdin, c.App.ErrWriter))
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare host key confirmation: %w", err)
+		}
+		return callback, nil
+	}
+
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts: %w", err)
+	}
+	return callback, nil
+}
+
+func writeJSON(path string, v any, owner *backup.Owner) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	return backup.WriteFileAtomic(path, append(data, '\n'), backupFileMode, owner)
+}
+
+// outputOwner resolves --output-owner and checks up front that the process
+// is allowed to hand files over, so a misconfiguration fails before any
+// device is contacted.
+func outputOwner(spec string) (*backup.Owner, error) {
+	if spec == "" {
+		return nil, nil //nolint:nilnil // no owner requested
+	}
+	owner, err := backup.ResolveOwner(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --output-owner: %w", err)
+	}
+	if err := owner.CheckPrivileges(); err != nil {
+		return nil, fmt.Errorf("invalid --output-owner: %w", err)
+	}
+	return &owner, nil
+}
+
+func compareDevicesCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "compare-devices",
+		Usage: "Compare the configuration of two MikroTik devices",
+		Description: `Export the configuration of two devices, normalize both and print
+the lines that differ. Useful for catching drift between redundant peers.
+Pass --host twice. Exits with an error when the configurations differ.`,
+		Flags: append(connectionFlags(),
+			&cli.StringSliceFlag{
+				Name:    "ignore",
+				Usage:   "Regular expression for normalized lines to ignore (may be repeated)",
+				EnvVars: []string{"MIKROTIK_COMPARE_IGNORE"},
+			},
+		),
+		Action: runCompareDevices,
+	}
+}
+
+func runCompareDevices(c *cli.Context) error {
+	config, err := backupConfig(c)
+	if err != nil {
+		return err
+	}
+	if err := validateAuth(config); err != nil {
+		return err
+	}
+
+	configs, err := deviceConfigs(c, config, c.StringSlice("host"))
+	if err != nil {
+		return err
+	}
+	if len(configs) != 2 { //nolint:mnd // a comparison has two sides
+		return fmt.Errorf("compare-devices requires exactly two devices, got %d", len(configs))
+	}
+
+	ignore := compare.DefaultIgnore()
+	for _, pattern := range c.StringSlice("ignore") {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid --ignore pattern %q: %w", pattern, err)
+		}
+		ignore = append(ignore, re)
+	}
+
+	hostKeyCallback, err := hostKeyCallback(c)
+	if err != nil {
+		return err
+	}
+
+	service := backup.New(ssh.NewClient(hostKeyCallback))
+	a := compare.Device{Exporter: service, Config: configs[0]}
+	b := compare.Device{Exporter: service, Config: configs[1]}
+
+	changes, err := compare.Devices(c.Context, a, b, ignore)
+	if err != nil {
+		return fmt.Errorf("comparison failed: %w", err)
+	}
+	if len(changes) == 0 {
+		_, _ = fmt.Fprintln(c.App.Writer, "Configurations are identical")
+		return nil
+	}
+
+	if err := compare.Write(c.App.Writer, configs[0].Host, configs[1].Host, changes); err != nil {
+		return err
+	}
+	return fmt.Errorf("configurations differ in %d lines", len(changes))
 }
 
 func versionCommand() *cli.Command {
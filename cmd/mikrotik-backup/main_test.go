@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+// backupContext parses args with the flags of the backup command.
+func backupContext(t *testing.T, args ...string) *cli.Context {
+	t.Helper()
+
+	set := flag.NewFlagSet("backup", flag.ContinueOnError)
+	for _, f := range backupCommand().Flags {
+		if err := f.Apply(set); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+	if err := set.Parse(args); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return cli.NewContext(cli.NewApp(), set, nil)
+}
+
+func TestBackupConfig_Routes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantRoutes  bool
+		wantIPv6    bool
+		wantDynamic bool
+	}{
+		{name: "disabled by default"},
+		{name: "ipv4 routes", args: []string{"--include-routes"}, wantRoutes: true},
+		{name: "ipv6 routes imply ipv4", args: []string{"--include-ipv6-routes"}, wantRoutes: true, wantIPv6: true},
+		{
+			name:        "dynamic routes",
+			args:        []string{"--include-routes", "--include-dynamic-routes"},
+			wantRoutes:  true,
+			wantDynamic: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			config, err := backupConfig(backupContext(t, tt.args...))
+			if err != nil {
+				t.Fatalf("backupConfig() error = %v", err)
+			}
+			if config.IncludeRoutes != tt.wantRoutes {
+				t.Errorf("IncludeRoutes = %v, want %v", config.IncludeRoutes, tt.wantRoutes)
+			}
+			if config.IncludeIPv6Routes != tt.wantIPv6 {
+				t.Errorf("IncludeIPv6Routes = %v, want %v", config.IncludeIPv6Routes, tt.wantIPv6)
+			}
+			if config.IncludeDynamicRoutes != tt.wantDynamic {
+				t.Errorf("IncludeDynamicRoutes = %v, want %v", config.IncludeDynamicRoutes, tt.wantDynamic)
+			}
+		})
+	}
+}
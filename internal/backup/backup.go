@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 )
 
 // Config holds the configuration for a backup operation.
@@ -13,12 +14,46 @@ type Config struct {
 	Port     int
 	Username string
 	Password string
-	KeyFile  string
+	KeyFiles []string
+	CertFile string
+
+	// AuthOrder lists authentication method names in the order they are
+	// tried. The SSH client applies its default order when empty.
+	AuthOrder []string
+
+	// ExportCommand replaces DefaultExportCommand. It must pass
+	// CheckReadOnly unless AllowWriteCommands is set.
+	ExportCommand      string
+	AllowWriteCommands bool
+
+	// IncludeIdentity captures the device identity into the returned Metadata.
+	IncludeIdentity bool
+
+	// IncludeChangeTime captures device uptime and the last configuration
+	// change time into the returned Metadata.
+	IncludeChangeTime bool
+
+	// IncludeRoutes captures the IPv4 routing table, and the IPv6 one with
+	// IncludeIPv6Routes, into Metadata.Routes. Dynamic and connected routes
+	// are left out unless IncludeDynamicRoutes is set.
+	IncludeRoutes        bool
+	IncludeIPv6Routes    bool
+	IncludeDynamicRoutes bool
+
+	// IncludeScripts captures every "/system script" source into
+	// Metadata.Scripts.
+	IncludeScripts bool
+
+	// MaxOutputSize caps the bytes accepted from any single command. A
+	// larger export fails Execute with ErrOutputTooLarge before anything is
+	// written to the output. Zero means no limit.
+	MaxOutputSize int64
 }
 
 // Service handles backup operations.
 type Service struct {
 	sshClient SSHClient
+	now       func() time.Time
 }
 
 // SSHClient defines the interface for SSH operations.
@@ -28,17 +63,40 @@ type SSHClient interface {
 	Close() error
 }
 
+// AuthMethodReporter is implemented by SSH clients that can report which
+// authentication method succeeded for the current connection.
+type AuthMethodReporter interface {
+	AuthMethod() string
+}
+
 // New creates a new backup service.
 func New(client SSHClient) *Service {
 	return &Service{
 		sshClient: client,
+		now:       time.Now,
 	}
 }
 
-// Execute performs a backup operation.
-func (s *Service) Execute(ctx context.Context, config Config, output io.Writer) error {
+// Execute performs a backup operation and returns the metadata captured
+// from the device.
+func (s *Service) Execute(ctx context.Context, config Config, output io.Writer) (Metadata, error) {
+	var meta Metadata
+
+	exportCommand := config.ExportCommand
+	if exportCommand == "" {
+		exportCommand = DefaultExportCommand
+	}
+	if !config.AllowWriteCommands {
+		if err := CheckReadOnly(exportCommand); err != nil {
+			return meta, fmt.Errorf("refusing export command: %w", err)
+		}
+	}
+
 	if err := s.sshClient.Connect(ctx, config); err != nil {
-		return fmt.Errorf("failed to connect: %w", err)
+		return meta, fmt.Errorf("failed to connect: %w", err)
+	}
+	if reporter, ok := s.sshClient.(AuthMethodReporter); ok {
+		meta.AuthMethod = reporter.AuthMethod()
 	}
 	defer func() {
 		if closeErr := s.sshClient.Close(); closeErr != nil {
@@ -48,13 +106,80 @@ func (s *Service) Execute(ctx context.Context, config Config, output io.Writer)
 	}()
 
 	// Export configuration
-	result, err := s.sshClient.ExecuteCommand(ctx, "/export")
+	result, err := s.sshClient.ExecuteCommand(ctx, exportCommand)
 	if err != nil {
-		return fmt.Errorf("failed to export configuration: %w", err)
+		return meta, fmt.Errorf("failed to export configuration: %w", err)
+	}
+	if config.MaxOutputSize > 0 && int64(len(result)) > config.MaxOutputSize {
+		return meta, fmt.Errorf("%w: export is %d bytes, limit is %d", ErrOutputTooLarge, len(result), config.MaxOutputSize)
+	}
+
+	if config.IncludeIdentity {
+		identity, err := s.sshClient.ExecuteCommand(ctx, "/system identity print")
+		if err != nil {
+			return meta, fmt.Errorf("failed to read system identity: %w", err)
+		}
+		meta.Identity, _ = ParseIdentity(identity)
+	}
+
+	if config.IncludeChangeTime {
+		if err := s.collectChangeTime(ctx, result, &meta); err != nil {
+			return meta, err
+		}
+	}
+
+	if config.IncludeRoutes {
+		routes, err := s.collectRoutes(ctx, config)
+		if err != nil {
+			return meta, err
+		}
+		meta.Routes = routes
+	}
+
+	if config.IncludeScripts {
+		scripts, err := s.collectScripts(ctx)
+		if err != nil {
+			return meta, err
+		}
+		meta.Scripts = scripts
 	}
 
 	if _, err := output.Write([]byte(result)); err != nil {
-		return fmt.Errorf("failed to write output: %w", err)
+		return meta, fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return meta, nil
+}
+
+// collectChangeTime fills in the device uptime and the last configuration
+// change time. The change time comes from "/system history" when it has
+// entries, falling back to the export header otherwise, including when the
+// history cannot be read.
+func (s *Service) collectChangeTime(ctx context.Context, export string, meta *Metadata) error {
+	resource, err := s.sshClient.ExecuteCommand(ctx, "/system resource print")
+	if err != nil {
+		return fmt.Errorf("failed to read system resources: %w", err)
+	}
+	uptime, _, err := ParseResourceUptime(resource)
+	if err != nil {
+		return fmt.Errorf("failed to parse uptime: %w", err)
+	}
+	meta.Uptime = uptime
+
+	// The history is optional; some RouterOS versions and restricted users
+	// cannot print it, and the export header still gives a change time.
+	history, err := s.sshClient.ExecuteCommand(ctx, "/system history print")
+	if err != nil {
+		history = ""
+	}
+
+	now := s.now()
+	if t, ok := ParseHistoryLastChange(history, now); ok {
+		meta.LastConfigChange = t
+		meta.LastConfigChangeSource = ChangeSourceHistory
+	} else if t, ok := ParseExportHeaderTime(export, now); ok {
+		meta.LastConfigChange = t
+		meta.LastConfigChangeSource = ChangeSourceExportHeader
 	}
 
 	return nil
@@ -51,11 +51,11 @@ func TestBackupIntegration(t *testing.T) {
 		Port:     22,
 		Username: username,
 		Password: password,
-		KeyFile:  keyFile,
+		KeyFiles: []string{keyFile},
 	}
 
 	// Execute backup
-	// err := service.Execute(ctx, config, output)
+	// _, err := service.Execute(ctx, config, output)
 	// if err != nil {
 	// 	t.Fatalf("Execute() failed: %v", err)
 	// }
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
 )
@@ -61,7 +62,7 @@ func TestService_Execute_Success(t *testing.T) {
 		Password: "password",
 	}
 
-	err := service.Execute(context.Background(), config, output)
+	_, err := service.Execute(context.Background(), config, output)
 	if err != nil {
 		t.Fatalf("Execute() error = %v, want nil", err)
 	}
@@ -92,7 +93,7 @@ func TestService_Execute_ConnectionError(t *testing.T) {
 		Password: "password",
 	}
 
-	err := service.Execute(context.Background(), config, output)
+	_, err := service.Execute(context.Background(), config, output)
 	if err == nil {
 		t.Fatal("Execute() error = nil, want error")
 	}
@@ -122,8 +123,192 @@ func TestService_Execute_CommandError(t *testing.T) {
 		Username: "admin",
 	}
 
-	err := service.Execute(context.Background(), config, output)
+	_, err := service.Execute(context.Background(), config, output)
 	if err == nil {
 		t.Fatal("Execute() error = nil, want error")
 	}
 }
+
+func TestService_Execute_IncludeChangeTime(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		history    string
+		historyErr error
+		wantChange time.Time
+		wantSource string
+	}{
+		{
+			name:       "from history",
+			history:    "0 U address added  admin  write  2024-02-14 08:00:01\n",
+			wantChange: time.Date(2024, time.February, 14, 8, 0, 1, 0, time.Local),
+			wantSource: backup.ChangeSourceHistory,
+		},
+		{
+			name:       "from export header",
+			history:    "Flags: U - undoable, R - redoable, F - floating-undo\n",
+			wantChange: time.Date(2024, time.January, 5, 10, 20, 30, 0, time.Local),
+			wantSource: backup.ChangeSourceExportHeader,
+		},
+		{
+			name:       "history unavailable",
+			historyErr: errors.New("bad command name history"),
+			wantChange: time.Date(2024, time.January, 5, 10, 20, 30, 0, time.Local),
+			wantSource: backup.ChangeSourceExportHeader,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &mockSSHClient{
+				executeCommandFunc: func(_ context.Context, cmd string) (string, error) {
+					switch cmd {
+					case "/export":
+						return "# 2024-01-05 10:20:30 by RouterOS 7.13.2\n", nil
+					case "/system resource print":
+						return "uptime: 1d2h\nversion: 7.13.2\n", nil
+					case "/system history print":
+						return tt.history, tt.historyErr
+					default:
+						t.Errorf("unexpected command: %s", cmd)
+						return "", nil
+					}
+				},
+			}
+
+			service := backup.New(client)
+			config := backup.Config{Host: "192.168.88.1", IncludeChangeTime: true}
+
+			meta, err := service.Execute(context.Background(), config, &bytes.Buffer{})
+			if err != nil {
+				t.Fatalf("Execute() error = %v, want nil", err)
+			}
+
+			if want := 26 * time.Hour; meta.Uptime != want {
+				t.Errorf("Execute() uptime = %v, want %v", meta.Uptime, want)
+			}
+			if !meta.LastConfigChange.Equal(tt.wantChange) {
+				t.Errorf("Execute() last change = %v, want %v", meta.LastConfigChange, tt.wantChange)
+			}
+			if meta.LastConfigChangeSource != tt.wantSource {
+				t.Errorf("Execute() change source = %q, want %q", meta.LastConfigChangeSource, tt.wantSource)
+			}
+		})
+	}
+}
+
+// reportingSSHClient is a mock SSH client that reports its authentication method.
+type reportingSSHClient struct {
+	mockSSHClient
+	authMethod string
+}
+
+func (m *reportingSSHClient) AuthMethod() string {
+	return m.authMethod
+}
+
+func TestService_Execute_ReportsAuthMethod(t *testing.T) {
+	t.Parallel()
+
+	client := &reportingSSHClient{authMethod: "key:/home/backup/.ssh/id_ed25519"}
+	service := backup.New(client)
+
+	meta, err := service.Execute(context.Background(), backup.Config{Host: "192.168.88.1"}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v, want nil", err)
+	}
+
+	if meta.AuthMethod != client.authMethod {
+		t.Errorf("Execute() auth method = %q, want %q", meta.AuthMethod, client.authMethod)
+	}
+}
+
+func TestService_Execute_IncludeIdentity(t *testing.T) {
+	t.Parallel()
+
+	client := &mockSSHClient{
+		executeCommandFunc: func(_ context.Context, cmd string) (string, error) {
+			if cmd == "/system identity print" {
+				return "  name: core-router\n", nil
+			}
+			return "", nil
+		},
+	}
+
+	service := backup.New(client)
+	config := backup.Config{Host: "192.168.88.1", IncludeIdentity: true}
+
+	meta, err := service.Execute(context.Background(), config, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v, want nil", err)
+	}
+
+	if meta.Identity != "core-router" {
+		t.Errorf("Execute() identity = %q, want %q", meta.Identity, "core-router")
+	}
+}
+
+func TestService_Execute_ExportCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		config     backup.Config
+		wantIssued string
+		wantErr    error
+	}{
+		{
+			name:       "read-only override",
+			config:     backup.Config{ExportCommand: "/export terse"},
+			wantIssued: "/export terse",
+		},
+		{
+			name:    "write command refused",
+			config:  backup.Config{ExportCommand: "/system backup save name=nightly"},
+			wantErr: backup.ErrWriteCommand,
+		},
+		{
+			name:       "write command allowed",
+			config:     backup.Config{ExportCommand: "/system backup save name=nightly", AllowWriteCommands: true},
+			wantIssued: "/system backup save name=nightly",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var issued []string
+			connected := false
+			client := &mockSSHClient{
+				connectFunc: func(_ context.Context, _ backup.Config) error {
+					connected = true
+					return nil
+				},
+				executeCommandFunc: func(_ context.Context, cmd string) (string, error) {
+					issued = append(issued, cmd)
+					return "# config\n", nil
+				},
+			}
+
+			service := backup.New(client)
+			_, err := service.Execute(context.Background(), tt.config, &bytes.Buffer{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				if connected {
+					t.Error("Execute() connected despite refused command")
+				}
+				return
+			}
+			if len(issued) != 1 || issued[0] != tt.wantIssued {
+				t.Errorf("Execute() issued %q, want [%q]", issued, tt.wantIssued)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DeviceResult describes the outcome of backing up one device in a batch.
+type DeviceResult struct {
+	Config   Config
+	Metadata Metadata
+	// Path is where the backup was stored; empty if the device failed
+	// before it was written.
+	Path string
+	Err  error
+}
+
+// WriteFunc stores the exported configuration of a device and returns the
+// path it was written to.
+type WriteFunc func(ctx context.Context, config Config, meta Metadata, data []byte) (string, error)
+
+// DeviceCallback is invoked once for every device in a batch as soon as it
+// has finished, whether it succeeded or not.
+type DeviceCallback func(ctx context.Context, result DeviceResult)
+
+// BatchOption configures a Batch.
+type BatchOption func(*Batch)
+
+// WithDeviceCallback registers fn to be called as each device completes.
+func WithDeviceCallback(fn DeviceCallback) BatchOption {
+	return func(b *Batch) {
+		b.callbacks = append(b.callbacks, fn)
+	}
+}
+
+// WithExportCallback registers fn to be called for every device exported
+// successfully, once the whole batch has been exported and before any
+// backup is written. The result has no Path yet. Registering one makes the
+// batch hold every export in memory until all devices have been exported,
+// and delays the device callbacks until then.
+func WithExportCallback(fn DeviceCallback) BatchOption {
+	return func(b *Batch) {
+		b.exportCallbacks = append(b.exportCallbacks, fn)
+	}
+}
+
+// Batch backs up several devices in turn.
+type Batch struct {
+	service         *Service
+	write           WriteFunc
+	callbacks       []DeviceCallback
+	exportCallbacks []DeviceCallback
+}
+
+// export is a device exported but not yet written.
+type export struct {
+	result DeviceResult
+	data   []byte
+}
+
+// NewBatch creates a batch that exports devices with service and stores
+// each backup with write.
+func NewBatch(service *Service, write WriteFunc, opts ...BatchOption) *Batch {
+	b := &Batch{
+		service: service,
+		write:   write,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Run backs up every device in configs. A failing device does not stop the
+// batch; all failures are returned together.
+func (b *Batch) Run(ctx context.Context, configs []Config) error {
+	var errs []error
+	if len(b.exportCallbacks) == 0 {
+		for _, config := range configs {
+			errs = append(errs, b.finish(ctx, b.export(ctx, config)))
+		}
+		return errors.Join(errs...)
+	}
+
+	exports := make([]export, 0, len(configs))
+	for _, config := range configs {
+		exports = append(exports, b.export(ctx, config))
+	}
+	for _, e := range exports {
+		if e.result.Err != nil {
+			continue
+		}
+		for _, fn := range b.exportCallbacks {
+			fn(ctx, e.result)
+		}
+	}
+	for _, e := range exports {
+		errs = append(errs, b.finish(ctx, e))
+	}
+	return errors.Join(errs...)
+}
+
+func (b *Batch) export(ctx context.Context, config Config) export {
+	output := &bytes.Buffer{}
+	meta, err := b.service.Execute(ctx, config, output)
+	result := DeviceResult{Config: config, Metadata: meta}
+	if err != nil {
+		result.Err = fmt.Errorf("backup failed: %w", err)
+		return export{result: result}
+	}
+	return export{result: result, data: output.Bytes()}
+}
+
+// finish writes an exported device, reports it to the device callbacks and
+// returns its error, if any, labeled with the host.
+func (b *Batch) finish(ctx context.Context, e export) error {
+	result := e.result
+	if result.Err == nil {
+		result.Path, result.Err = b.write(ctx, result.Config, result.Metadata, e.data)
+	}
+	for _, fn := range b.callbacks {
+		fn(ctx, result)
+	}
+	if result.Err != nil {
+		return fmt.Errorf("%s: %w", result.Config.Host, result.Err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package backup_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+func TestBatch_Run(t *testing.T) {
+	t.Parallel()
+
+	errUnreachable := errors.New("unreachable")
+	host := ""
+	client := &mockSSHClient{
+		connectFunc: func(_ context.Context, config backup.Config) error {
+			host = config.Host
+			if host == "10.0.0.2" {
+				return errUnreachable
+			}
+			return nil
+		},
+		executeCommandFunc: func(_ context.Context, cmd string) (string, error) {
+			if cmd == "/system identity print" {
+				return "name: router-" + host + "\n", nil
+			}
+			return "# export of " + host + "\n", nil
+		},
+	}
+
+	written := map[string]string{}
+	write := func(_ context.Context, config backup.Config, _ backup.Metadata, data []byte) (string, error) {
+		path := "/backups/" + config.Host + ".rsc"
+		written[path] = string(data)
+		return path, nil
+	}
+
+	var results []backup.DeviceResult
+	batch := backup.NewBatch(backup.New(client), write,
+		backup.WithDeviceCallback(func(_ context.Context, result backup.DeviceResult) {
+			results = append(results, result)
+		}),
+	)
+
+	configs := []backup.Config{
+		{Host: "10.0.0.1", IncludeIdentity: true},
+		{Host: "10.0.0.2", IncludeIdentity: true},
+		{Host: "10.0.0.3", IncludeIdentity: true},
+	}
+
+	err := batch.Run(context.Background(), configs)
+	if !errors.Is(err, errUnreachable) {
+		t.Fatalf("Run() error = %v, want %v", err, errUnreachable)
+	}
+	if !strings.Contains(err.Error(), "10.0.0.2") {
+		t.Errorf("Run() error = %v, should name the failed host", err)
+	}
+
+	if len(results) != len(configs) {
+		t.Fatalf("callback fired %d times, want %d", len(results), len(configs))
+	}
+
+	for i, want := range []struct {
+		host     string
+		identity string
+		path     string
+		failed   bool
+	}{
+		{host: "10.0.0.1", identity: "router-10.0.0.1", path: "/backups/10.0.0.1.rsc"},
+		{host: "10.0.0.2", failed: true},
+		{host: "10.0.0.3", identity: "router-10.0.0.3", path: "/backups/10.0.0.3.rsc"},
+	} {
+		got := results[i]
+		if got.Config.Host != want.host {
+			t.Errorf("result %d host = %q, want %q", i, got.Config.Host, want.host)
+		}
+		if got.Metadata.Identity != want.identity {
+			t.Errorf("result %d identity = %q, want %q", i, got.Metadata.Identity, want.identity)
+		}
+		if got.Path != want.path {
+			t.Errorf("result %d path = %q, want %q", i, got.Path, want.path)
+		}
+		if (got.Err != nil) != want.failed {
+			t.Errorf("result %d error = %v, want failed %v", i, got.Err, want.failed)
+		}
+	}
+
+	if got := written["/backups/10.0.0.3.rsc"]; got != "# export of 10.0.0.3\n" {
+		t.Errorf("written backup = %q, want export of 10.0.0.3", got)
+	}
+}
+
+func TestBatch_Run_ExportCallback(t *testing.T) {
+	t.Parallel()
+
+	host := ""
+	client := &mockSSHClient{
+		connectFunc: func(_ context.Context, config backup.Config) error {
+			host = config.Host
+			if host == "10.0.0.2" {
+				return errors.New("unreachable")
+			}
+			return nil
+		},
+		executeCommandFunc: func(context.Context, string) (string, error) {
+			return "# export of " + host + "\n", nil
+		},
+	}
+
+	var events []string
+	write := func(_ context.Context, config backup.Config, _ backup.Metadata, _ []byte) (string, error) {
+		events = append(events, "write "+config.Host)
+		return "/backups/" + config.Host + ".rsc", nil
+	}
+	batch := backup.NewBatch(backup.New(client), write,
+		backup.WithExportCallback(func(_ context.Context, result backup.DeviceResult) {
+			events = append(events, "export "+result.Config.Host)
+		}),
+		backup.WithDeviceCallback(func(_ context.Context, result backup.DeviceResult) {
+			events = append(events, "done "+result.Config.Host)
+		}),
+	)
+
+	configs := []backup.Config{{Host: "10.0.0.1"}, {Host: "10.0.0.2"}, {Host: "10.0.0.3"}}
+	if err := batch.Run(context.Background(), configs); err == nil {
+		t.Fatal("Run() error = nil, want the failure of 10.0.0.2")
+	}
+
+	want := []string{
+		"export 10.0.0.1", "export 10.0.0.3",
+		"write 10.0.0.1", "done 10.0.0.1",
+		"done 10.0.0.2",
+		"write 10.0.0.3", "done 10.0.0.3",
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
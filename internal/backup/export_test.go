@@ -0,0 +1,7 @@
+package backup
+
+// CheckPrivilegesAs exposes checkPrivileges so tests running as root can
+// cover the rules for unprivileged processes.
+func (o Owner) CheckPrivilegesAs(euid int, groups []int) error {
+	return o.checkPrivileges(euid, groups)
+}
@@ -0,0 +1,197 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrChownUnsupported is returned when file ownership cannot be changed
+	// on the current platform.
+	ErrChownUnsupported = errors.New("changing file ownership is not supported on " + runtime.GOOS)
+
+	// ErrInsufficientPrivileges is returned when the process may not give
+	// files to the requested owner.
+	ErrInsufficientPrivileges = errors.New("insufficient privileges to change file ownership")
+)
+
+// Owner is the user and group that written files are given to. A value of
+// -1 leaves the corresponding id unchanged.
+type Owner struct {
+	UID int
+	GID int
+}
+
+// ResolveOwner parses "user[:group]" into an Owner. Names are resolved with
+// os/user; numeric ids are used as is. Without a group, the user's primary
+// group is used, or the group is left unchanged for a numeric uid that has
+// no account.
+func ResolveOwner(spec string) (Owner, error) {
+	userPart, groupPart, hasGroup := strings.Cut(strings.TrimSpace(spec), ":")
+	if userPart == "" {
+		return Owner{}, fmt.Errorf("invalid owner %q: missing user", spec)
+	}
+
+	u, err := lookupUser(userPart)
+	if err != nil {
+		return Owner{}, err
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return Owner{}, fmt.Errorf("user %s has non-numeric uid %q", userPart, u.Uid)
+	}
+
+	gidText := u.Gid
+	if hasGroup && groupPart != "" {
+		g, err := lookupGroup(groupPart)
+		if err != nil {
+			return Owner{}, err
+		}
+		gidText = g.Gid
+	}
+	gid, err := strconv.Atoi(gidText)
+	if err != nil {
+		return Owner{}, fmt.Errorf("group of %s has non-numeric gid %q", spec, gidText)
+	}
+
+	return Owner{UID: uid, GID: gid}, nil
+}
+
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		if u, err := user.LookupId(name); err == nil {
+			return u, nil
+		}
+		// Numeric ids without a passwd entry are still valid owners.
+		return &user.User{Uid: name, Gid: "-1"}, nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve user %s: %w", name, err)
+	}
+	return u, nil
+}
+
+func lookupGroup(name string) (*user.Group, error) {
+	if _, err := strconv.Atoi(name); err == nil {
+		return &user.Group{Gid: name}, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve group %s: %w", name, err)
+	}
+	return g, nil
+}
+
+// CheckPrivileges reports whether the process can give files to o. Only
+// root may give files to another user or to a group it is not a member of.
+func (o Owner) CheckPrivileges() error {
+	if runtime.GOOS == "windows" {
+		return ErrChownUnsupported
+	}
+	euid := os.Geteuid()
+	if euid == 0 {
+		return nil
+	}
+	groups, err := os.Getgroups()
+	if err != nil {
+		return fmt.Errorf("failed to list groups: %w", err)
+	}
+	return o.checkPrivileges(euid, append(groups, os.Getegid()))
+}
+
+// checkPrivileges applies the chown rules for an unprivileged process
+// running as euid and belonging to groups.
+func (o Owner) checkPrivileges(euid int, groups []int) error {
+	if o.UID != -1 && o.UID != euid {
+		return fmt.Errorf("%w: uid %d cannot give files to uid %d, run as root", ErrInsufficientPrivileges, euid, o.UID)
+	}
+	if o.GID != -1 && !slices.Contains(groups, o.GID) {
+		return fmt.Errorf("%w: uid %d is not a member of gid %d, run as root", ErrInsufficientPrivileges, euid, o.GID)
+	}
+	return nil
+}
+
+// Chown gives path to o.
+func (o Owner) Chown(path string) error {
+	if err := os.Chown(path, o.UID, o.GID); err != nil {
+		return fmt.Errorf("failed to change owner of %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteFileAtomic writes data to path through a temporary file in the same
+// directory that is renamed into place, so readers never see a partial
+// file and a failed write leaves any previous file untouched. When owner
+// is not nil, the file is chowned after the rename.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode, owner *Owner) error {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+name+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", path, err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+
+	if owner != nil {
+		return owner.Chown(path)
+	}
+	return nil
+}
+
+// MkdirAll creates dir and any missing parents, chowning the directories it
+// created to owner when owner is not nil.
+func MkdirAll(dir string, perm os.FileMode, owner *Owner) error {
+	var created []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		created = append(created, d)
+		if parent := filepath.Dir(d); parent == d {
+			break
+		}
+	}
+
+	if err := os.MkdirAll(dir, perm); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	if owner != nil {
+		for _, d := range created {
+			if err := owner.Chown(d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package backup_test
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+func currentOwner(t *testing.T) (*user.User, *user.Group) {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("ownership lookups are not supported on windows")
+	}
+	u, err := user.Current()
+	if err != nil {
+		t.Skipf("cannot determine current user: %v", err)
+	}
+	g, err := user.LookupGroupId(u.Gid)
+	if err != nil {
+		t.Skipf("cannot determine current group: %v", err)
+	}
+	return u, g
+}
+
+func TestResolveOwner(t *testing.T) {
+	t.Parallel()
+
+	u, g := currentOwner(t)
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(g.Gid)
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    backup.Owner
+		wantErr bool
+	}{
+		{name: "user name uses primary group", spec: u.Username, want: backup.Owner{UID: uid, GID: gid}},
+		{name: "user and group names", spec: u.Username + ":" + g.Name, want: backup.Owner{UID: uid, GID: gid}},
+		{name: "trailing colon", spec: u.Username + ":", want: backup.Owner{UID: uid, GID: gid}},
+		{name: "numeric ids", spec: "64123:64124", want: backup.Owner{UID: 64123, GID: 64124}},
+		{name: "numeric uid without account", spec: "64123", want: backup.Owner{UID: 64123, GID: -1}},
+		{name: "unknown user", spec: "no-such-backup-user", wantErr: true},
+		{name: "unknown group", spec: u.Username + ":no-such-backup-group", wantErr: true},
+		{name: "missing user", spec: ":" + g.Name, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := backup.ResolveOwner(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveOwner(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveOwner(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOwner_CheckPrivileges(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		if err := (backup.Owner{UID: 0, GID: 0}).CheckPrivileges(); !errors.Is(err, backup.ErrChownUnsupported) {
+			t.Errorf("CheckPrivileges() error = %v, want ErrChownUnsupported", err)
+		}
+		return
+	}
+
+	self := backup.Owner{UID: os.Geteuid(), GID: -1}
+	if err := self.CheckPrivileges(); err != nil {
+		t.Errorf("CheckPrivileges() for current user error = %v", err)
+	}
+
+	other := backup.Owner{UID: os.Geteuid() + 1, GID: -1}
+	err := other.CheckPrivileges()
+	if os.Geteuid() == 0 {
+		if err != nil {
+			t.Errorf("CheckPrivileges() as root error = %v", err)
+		}
+	} else if !errors.Is(err, backup.ErrInsufficientPrivileges) {
+		t.Errorf("CheckPrivileges() error = %v, want ErrInsufficientPrivileges", err)
+	}
+}
+
+func TestOwner_CheckPrivilegesUnprivileged(t *testing.T) {
+	t.Parallel()
+
+	const euid = 1000
+	groups := []int{1000, 27}
+
+	tests := []struct {
+		name    string
+		owner   backup.Owner
+		wantErr bool
+	}{
+		{name: "own user and primary group", owner: backup.Owner{UID: euid, GID: 1000}},
+		{name: "own user and supplementary group", owner: backup.Owner{UID: euid, GID: 27}},
+		{name: "own user, group unchanged", owner: backup.Owner{UID: euid, GID: -1}},
+		{name: "group only", owner: backup.Owner{UID: -1, GID: 27}},
+		{name: "other user", owner: backup.Owner{UID: 1001, GID: 1000}, wantErr: true},
+		{name: "own user and foreign group", owner: backup.Owner{UID: euid, GID: 1001}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.owner.CheckPrivilegesAs(euid, groups)
+			if tt.wantErr != errors.Is(err, backup.ErrInsufficientPrivileges) {
+				t.Errorf("CheckPrivilegesAs() error = %v, want ErrInsufficientPrivileges: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "router.rsc")
+	writeFiles(t, dir, "router.rsc")
+
+	if err := backup.WriteFileAtomic(path, []byte("new config\n"), 0o600, nil); err != nil {
+		t.Fatalf("WriteFileAtomic() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // test file
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != "new config\n" {
+		t.Errorf("file content = %q, want %q", data, "new config\n")
+	}
+	if got := listFiles(t, dir); len(got) != 1 {
+		t.Errorf("files after WriteFileAtomic() = %q, want only the backup", got)
+	}
+}
+
+func TestWriteFileAtomic_ChownsToOwner(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("chown is not supported on windows")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "router.rsc")
+
+	// Giving files to ourselves needs no privileges.
+	owner := &backup.Owner{UID: os.Geteuid(), GID: os.Getegid()}
+	if err := backup.MkdirAll(filepath.Dir(path), 0o750, owner); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := backup.WriteFileAtomic(path, []byte("config\n"), 0o600, owner); err != nil {
+		t.Fatalf("WriteFileAtomic() error = %v", err)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Stat() error = %v", err)
+	}
+}
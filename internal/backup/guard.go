@@ -0,0 +1,50 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultExportCommand is the command used to export the configuration.
+const DefaultExportCommand = "/export"
+
+// ErrWriteCommand is returned when a command could modify the device.
+var ErrWriteCommand = errors.New("command is not read-only")
+
+// CheckReadOnly reports an error unless cmd is a single RouterOS export,
+// print or get command. The first verb found in the command path decides:
+// "/system script print" passes while "/system script run" or
+// "/file remove print" do not. Sub-commands, command separators and
+// "file=" arguments, which make export write to the device, are rejected.
+func CheckReadOnly(cmd string) error {
+	if strings.TrimSpace(cmd) == "" {
+		return fmt.Errorf("%w: empty command", ErrWriteCommand)
+	}
+	if strings.ContainsAny(cmd, ";[]{}$\n") {
+		return fmt.Errorf("%w: %q contains a command separator or sub-command", ErrWriteCommand, cmd)
+	}
+
+	for _, token := range strings.Fields(cmd) {
+		if strings.HasPrefix(token, "file=") {
+			return fmt.Errorf("%w: %q writes to a file on the device", ErrWriteCommand, cmd)
+		}
+	}
+
+	for _, token := range strings.Fields(cmd) {
+		if strings.Contains(token, "=") {
+			break
+		}
+		for _, segment := range strings.Split(token, "/") {
+			switch segment {
+			case "export", "print", "get":
+				return nil
+			case "add", "set", "unset", "remove", "enable", "disable", "move", "edit", "comment",
+				"reset", "reset-configuration", "reboot", "shutdown", "import", "run", "upgrade":
+				return fmt.Errorf("%w: %q", ErrWriteCommand, cmd)
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: %q is not an export, print or get command", ErrWriteCommand, cmd)
+}
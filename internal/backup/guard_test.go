@@ -0,0 +1,47 @@
+package backup_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+func TestCheckReadOnly(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		cmd     string
+		wantErr bool
+	}{
+		{cmd: "/export", wantErr: false},
+		{cmd: "/export terse show-sensitive", wantErr: false},
+		{cmd: "export compact", wantErr: false},
+		{cmd: "/system script print detail", wantErr: false},
+		{cmd: "/system/script/print where name=backup", wantErr: false},
+		{cmd: "/system identity get name", wantErr: false},
+		{cmd: "", wantErr: true},
+		{cmd: "/export file=backup", wantErr: true},
+		{cmd: "/export; /system reboot", wantErr: true},
+		{cmd: "/export [/system reset-configuration]", wantErr: true},
+		{cmd: "/system reset-configuration", wantErr: true},
+		{cmd: "/file remove print", wantErr: true},
+		{cmd: "/ip address add address=10.0.0.1/24 interface=print", wantErr: true},
+		{cmd: "/system script run export", wantErr: true},
+		{cmd: "/interface", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cmd, func(t *testing.T) {
+			t.Parallel()
+
+			err := backup.CheckReadOnly(tt.cmd)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckReadOnly(%q) error = %v, wantErr %v", tt.cmd, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, backup.ErrWriteCommand) {
+				t.Errorf("CheckReadOnly(%q) error = %v, want ErrWriteCommand", tt.cmd, err)
+			}
+		})
+	}
+}
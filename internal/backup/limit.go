@@ -0,0 +1,41 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrOutputTooLarge is returned when a device sends more output than
+// Config.MaxOutputSize allows.
+var ErrOutputTooLarge = errors.New("output too large")
+
+// LimitedWriter writes to W until N bytes have been written in total. A
+// write going past N is truncated at the limit and fails with
+// ErrOutputTooLarge, as do all writes after it. N <= 0 disables the limit.
+type LimitedWriter struct {
+	W io.Writer
+	N int64
+
+	written int64
+}
+
+// Write implements io.Writer.
+func (l *LimitedWriter) Write(p []byte) (int, error) {
+	if l.N <= 0 {
+		return l.W.Write(p)
+	}
+
+	if room := l.N - l.written; int64(len(p)) > room {
+		n, err := l.W.Write(p[:max(room, 0)])
+		l.written += int64(n)
+		if err != nil {
+			return n, err
+		}
+		return n, fmt.Errorf("%w: more than %d bytes", ErrOutputTooLarge, l.N)
+	}
+
+	n, err := l.W.Write(p)
+	l.written += int64(n)
+	return n, err
+}
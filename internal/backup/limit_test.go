@@ -0,0 +1,128 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+func TestLimitedWriter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		limit   int64
+		writes  []string
+		want    string
+		wantErr bool
+	}{
+		{name: "under limit", limit: 10, writes: []string{"abc", "def"}, want: "abcdef"},
+		{name: "exactly at limit", limit: 6, writes: []string{"abc", "def"}, want: "abcdef"},
+		{name: "crossing limit", limit: 4, writes: []string{"abc", "def"}, want: "abcd", wantErr: true},
+		{name: "after limit", limit: 3, writes: []string{"abc", "d", "e"}, want: "abc", wantErr: true},
+		{name: "no limit", writes: []string{strings.Repeat("x", 1000)}, want: strings.Repeat("x", 1000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			w := &backup.LimitedWriter{W: &buf, N: tt.limit}
+			var err error
+			for _, s := range tt.writes {
+				if _, err = w.Write([]byte(s)); err != nil {
+					break
+				}
+			}
+
+			if tt.wantErr != errors.Is(err, backup.ErrOutputTooLarge) {
+				t.Errorf("Write() error = %v, want ErrOutputTooLarge: %v", err, tt.wantErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("written = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestService_Execute_MaxOutputSize(t *testing.T) {
+	t.Parallel()
+
+	export := strings.Repeat("/interface print\n", 100)
+	tests := []struct {
+		name    string
+		limit   int64
+		wantErr bool
+	}{
+		{name: "no limit", limit: 0},
+		{name: "exactly at limit", limit: int64(len(export))},
+		{name: "over limit", limit: int64(len(export)) - 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &mockSSHClient{
+				executeCommandFunc: func(context.Context, string) (string, error) { return export, nil },
+			}
+			output := &bytes.Buffer{}
+			_, err := backup.New(client).Execute(context.Background(), backup.Config{MaxOutputSize: tt.limit}, output)
+
+			if tt.wantErr {
+				if !errors.Is(err, backup.ErrOutputTooLarge) {
+					t.Errorf("Execute() error = %v, want ErrOutputTooLarge", err)
+				}
+				if output.Len() != 0 {
+					t.Errorf("Execute() wrote %d bytes of an oversized export, want none", output.Len())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if output.String() != export {
+				t.Errorf("Execute() wrote %d bytes, want %d", output.Len(), len(export))
+			}
+		})
+	}
+}
+
+func TestBatch_Run_MaxOutputSize(t *testing.T) {
+	t.Parallel()
+
+	client := &mockSSHClient{
+		executeCommandFunc: func(context.Context, string) (string, error) {
+			return strings.Repeat("/interface print\n", 1000), nil
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "router.rsc")
+	if err := os.WriteFile(path, []byte("good backup\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	write := func(_ context.Context, _ backup.Config, _ backup.Metadata, data []byte) (string, error) {
+		return path, backup.WriteFileAtomic(path, data, 0o600, nil)
+	}
+
+	batch := backup.NewBatch(backup.New(client), write)
+	err := batch.Run(context.Background(), []backup.Config{{Host: "10.0.0.1", MaxOutputSize: 1024}})
+	if !errors.Is(err, backup.ErrOutputTooLarge) {
+		t.Fatalf("Run() error = %v, want ErrOutputTooLarge", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "good backup\n" {
+		t.Errorf("backup was replaced with %d bytes of partial output", len(data))
+	}
+}
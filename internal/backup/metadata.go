@@ -0,0 +1,223 @@
+package backup
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Change time sources reported in Metadata.LastConfigChangeSource.
+const (
+	ChangeSourceHistory      = "history"
+	ChangeSourceExportHeader = "export-header"
+)
+
+// Metadata holds device information captured alongside a backup.
+type Metadata struct {
+	Identity               string        `json:"identity,omitempty"`
+	AuthMethod             string        `json:"auth_method,omitempty"`
+	Uptime                 time.Duration `json:"-"`
+	LastConfigChange       time.Time     `json:"last_config_change,omitzero"`
+	LastConfigChangeSource string        `json:"last_config_change_source,omitempty"`
+
+	// Routes and Scripts are stored separately from the rest of the
+	// metadata because of their size.
+	Routes  *Routes  `json:"-"`
+	Scripts []Script `json:"-"`
+}
+
+// MarshalJSON encodes Metadata with Uptime as whole seconds, so that the
+// sidecar stays readable.
+func (m Metadata) MarshalJSON() ([]byte, error) {
+	type plain Metadata
+	return json.Marshal(struct {
+		plain
+		UptimeSeconds int64 `json:"uptime_seconds,omitempty"`
+	}{plain(m), int64(m.Uptime / time.Second)})
+}
+
+// ErrInvalidUptime is returned when an uptime value cannot be parsed.
+var ErrInvalidUptime = errors.New("invalid uptime")
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+// timestampPattern matches the timestamp forms printed by RouterOS v6 and v7:
+// "2024-01-05 10:20:30", "jan/05/2024 10:20:30", "jan/05 10:20:30" and "10:20:30".
+var timestampPattern = regexp.MustCompile(
+	`(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}|[A-Za-z]{3}/\d{2}(?:/\d{4})? \d{2}:\d{2}:\d{2}|\d{2}:\d{2}:\d{2})\s*$`)
+
+// ParseUptime parses a RouterOS uptime value such as "2w3d04:05:06",
+// "1d2h3m4s" or "5m10s450ms". A "key: value" line as printed by
+// "/system resource print" is also accepted.
+func ParseUptime(value string) (time.Duration, error) {
+	s := strings.TrimSpace(value)
+	if key, rest, ok := strings.Cut(s, ":"); ok && strings.TrimSpace(key) == "uptime" {
+		s = strings.TrimSpace(rest)
+	}
+	if s == "" {
+		return 0, fmt.Errorf("%w: empty value", ErrInvalidUptime)
+	}
+
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		if i > 0 && i < len(rest) && rest[i] == ':' {
+			clock, err := parseClock(rest)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %q", ErrInvalidUptime, value)
+			}
+			return total + clock, nil
+		}
+
+		j := i
+		for j < len(rest) && (rest[j] < '0' || rest[j] > '9') {
+			j++
+		}
+		if i == 0 || i == j {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidUptime, value)
+		}
+
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidUptime, value)
+		}
+		unit, ok := uptimeUnit(rest[i:j])
+		if !ok {
+			return 0, fmt.Errorf("%w: unknown unit %q", ErrInvalidUptime, rest[i:j])
+		}
+		total += time.Duration(n) * unit
+		rest = rest[j:]
+	}
+
+	return total, nil
+}
+
+func uptimeUnit(s string) (time.Duration, bool) {
+	switch s {
+	case "w":
+		return week, true
+	case "d":
+		return day, true
+	case "h":
+		return time.Hour, true
+	case "m":
+		return time.Minute, true
+	case "s":
+		return time.Second, true
+	case "ms":
+		return time.Millisecond, true
+	default:
+		return 0, false
+	}
+}
+
+func parseClock(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 { //nolint:mnd // hh:mm:ss
+		return 0, errors.New("clock value must be hh:mm:ss")
+	}
+	var total time.Duration
+	for _, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+		n, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse clock value: %w", err)
+		}
+		total += time.Duration(n) * unit
+		parts = parts[1:]
+	}
+	return total, nil
+}
+
+// ParseResourceUptime extracts the uptime from "/system resource print" output.
+// It returns false if no uptime line is present.
+func ParseResourceUptime(output string) (time.Duration, bool, error) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(key) != "uptime" {
+			continue
+		}
+		d, err := ParseUptime(value)
+		if err != nil {
+			return 0, false, err
+		}
+		return d, true, nil
+	}
+	return 0, false, nil
+}
+
+// ParseHistoryLastChange returns the most recent timestamp found in
+// "/system history print" output. Partial timestamps, which RouterOS prints
+// for entries from the current year or day, are resolved against ref.
+func ParseHistoryLastChange(output string, ref time.Time) (time.Time, bool) {
+	var latest time.Time
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "Flags:") {
+			continue
+		}
+		m := timestampPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		t, ok := parseTimestamp(m[1], ref)
+		if ok && t.After(latest) {
+			latest = t
+		}
+	}
+	return latest, !latest.IsZero()
+}
+
+// ParseExportHeaderTime returns the timestamp from the first line of an
+// export, e.g. "# 2024-01-05 10:20:30 by RouterOS 7.13.2".
+func ParseExportHeaderTime(export string, ref time.Time) (time.Time, bool) {
+	first, _, _ := strings.Cut(export, "\n")
+	first = strings.TrimSpace(first)
+	if !strings.HasPrefix(first, "#") {
+		return time.Time{}, false
+	}
+	stamp, _, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(first, "#")), " by ")
+	if !ok {
+		return time.Time{}, false
+	}
+	return parseTimestamp(strings.TrimSpace(stamp), ref)
+}
+
+func parseTimestamp(s string, ref time.Time) (time.Time, bool) {
+	loc := ref.Location()
+	for _, layout := range []string{"2006-01-02 15:04:05", "Jan/02/2006 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, normalizeMonth(s), loc); err == nil {
+			return t, true
+		}
+	}
+	if t, err := time.ParseInLocation("Jan/02 15:04:05", normalizeMonth(s), loc); err == nil {
+		return time.Date(ref.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc), true
+	}
+	if t, err := time.ParseInLocation("15:04:05", s, loc); err == nil {
+		y, mo, d := ref.Date()
+		return time.Date(y, mo, d, t.Hour(), t.Minute(), t.Second(), 0, loc), true
+	}
+	return time.Time{}, false
+}
+
+// normalizeMonth capitalizes a leading lowercase month name ("jan" -> "Jan")
+// so it matches Go's reference layout.
+func normalizeMonth(s string) string {
+	if len(s) < 3 || s[0] < 'a' || s[0] > 'z' {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
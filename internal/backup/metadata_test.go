@@ -0,0 +1,191 @@
+package backup_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+func TestParseUptime(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "units", input: "1d2h3m4s", want: 26*time.Hour + 3*time.Minute + 4*time.Second},
+		{name: "weeks and clock", input: "2w3d04:05:06", want: 17*24*time.Hour + 4*time.Hour + 5*time.Minute + 6*time.Second},
+		{name: "clock only", input: "00:05:06", want: 5*time.Minute + 6*time.Second},
+		{name: "milliseconds", input: "5m10s450ms", want: 5*time.Minute + 10*time.Second + 450*time.Millisecond},
+		{name: "resource line", input: "                  uptime: 3h12m", want: 3*time.Hour + 12*time.Minute},
+		{name: "empty", input: "", wantErr: true},
+		{name: "unknown unit", input: "3y", wantErr: true},
+		{name: "garbage", input: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := backup.ParseUptime(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseUptime(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, backup.ErrInvalidUptime) {
+				t.Errorf("ParseUptime(%q) error = %v, want ErrInvalidUptime", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseUptime(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseResourceUptime(t *testing.T) {
+	t.Parallel()
+
+	output := `                   uptime: 1w2d03:04:05
+                  version: 6.49.10 (long-term)
+               build-time: Sep/06/2023 09:44:05
+              free-memory: 44.2MiB
+`
+
+	got, ok, err := backup.ParseResourceUptime(output)
+	if err != nil {
+		t.Fatalf("ParseResourceUptime() error = %v", err)
+	}
+	if !ok {
+		t.Fatal("ParseResourceUptime() found no uptime")
+	}
+	want := 9*24*time.Hour + 3*time.Hour + 4*time.Minute + 5*time.Second
+	if got != want {
+		t.Errorf("ParseResourceUptime() = %v, want %v", got, want)
+	}
+
+	if _, ok, _ := backup.ParseResourceUptime("version: 7.13\n"); ok {
+		t.Error("ParseResourceUptime() found uptime in output without one")
+	}
+}
+
+func TestParseHistoryLastChange(t *testing.T) {
+	t.Parallel()
+
+	ref := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		output string
+		want   time.Time
+		wantOK bool
+	}{
+		{
+			name: "routeros 7",
+			output: `Flags: U - UNDOABLE
+Columns: ACTION, BY, POLICY, TIME
+#   ACTION                 BY     POLICY  TIME
+0 U ip address added       admin  write   2024-01-05 10:20:30
+1 U firewall rule changed  admin  write   2024-02-14 08:00:01
+`,
+			want:   time.Date(2024, time.February, 14, 8, 0, 1, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name: "routeros 6 with partial dates",
+			output: `Flags: U - undoable, R - redoable, F - floating-undo
+ #   ACTION                                   BY                   POLICY
+ 0 U route changed                            admin                write  09:15:00
+ 1 U address added                            admin                write  mar/02 17:45:12
+ 2 U identity changed                         admin                write  dec/30/2023 23:59:59
+`,
+			want:   time.Date(2024, time.March, 10, 9, 15, 0, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "empty history",
+			output: "Flags: U - undoable, R - redoable, F - floating-undo\n",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := backup.ParseHistoryLastChange(tt.output, ref)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseHistoryLastChange() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseHistoryLastChange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExportHeaderTime(t *testing.T) {
+	t.Parallel()
+
+	ref := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		export string
+		want   time.Time
+		wantOK bool
+	}{
+		{
+			name:   "iso header",
+			export: "# 2024-01-05 10:20:30 by RouterOS 7.13.2\n# software id = ABCD-1234\n",
+			want:   time.Date(2024, time.January, 5, 10, 20, 30, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "legacy header",
+			export: "# jan/05/2024 10:20:30 by RouterOS 6.49.10\n",
+			want:   time.Date(2024, time.January, 5, 10, 20, 30, 0, time.UTC),
+			wantOK: true,
+		},
+		{
+			name:   "no header",
+			export: "/system identity\nset name=test\n",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := backup.ParseExportHeaderTime(tt.export, ref)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseExportHeaderTime() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseExportHeaderTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadata_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	meta := backup.Metadata{
+		Identity: "core-router",
+		Uptime:   26*time.Hour + 1500*time.Millisecond,
+		Routes:   &backup.Routes{},
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `{"identity":"core-router","uptime_seconds":93601}`; string(data) != want {
+		t.Errorf("Marshal() = %s, want %s", data, want)
+	}
+}
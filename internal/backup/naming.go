@@ -0,0 +1,103 @@
+package backup
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+)
+
+// ParseIdentity extracts the device name from "/system identity print" output.
+func ParseIdentity(output string) (string, bool) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(key) != "name" {
+			continue
+		}
+		name := strings.Trim(strings.TrimSpace(value), `"`)
+		return name, name != ""
+	}
+	return "", false
+}
+
+// SanitizeFilename turns s into a safe base filename by replacing every
+// character outside [A-Za-z0-9._-] with an underscore. Leading dots are
+// dropped so the result is never hidden or a relative path component.
+func SanitizeFilename(s string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return strings.TrimLeft(b.String(), ".")
+}
+
+// Namer assigns unique backup base names to devices in a batch. A name only
+// depends on the devices observed, not on the order they are named in, so a
+// device keeps its file when hosts are listed or resolved in another order.
+type Namer struct {
+	// claims maps each base name to the hosts observed wanting it.
+	claims map[string]map[string]bool
+	// owners maps each name handed out to its host.
+	owners map[string]string
+}
+
+// NewNamer creates a Namer with no names assigned.
+func NewNamer() *Namer {
+	return &Namer{claims: map[string]map[string]bool{}, owners: map[string]string{}}
+}
+
+// Observe records that the device at host reports identity. Observe every
+// device of a batch before naming any so duplicates are known up front.
+func (n *Namer) Observe(identity, host string) {
+	base := baseName(identity, host)
+	if n.claims[base] == nil {
+		n.claims[base] = map[string]bool{}
+	}
+	n.claims[base][host] = true
+}
+
+// Name returns the base filename for the device at host. The sanitized
+// identity is used when present, otherwise the sanitized host. Every device
+// sharing an identity with another observed device gets its host appended,
+// and a numeric suffix is added while the name is still used by another
+// device.
+func (n *Namer) Name(identity, host string) string {
+	n.Observe(identity, host)
+
+	base := baseName(identity, host)
+	if len(n.claims[base]) > 1 {
+		base = base + "-" + SanitizeFilename(host)
+	}
+	name := base
+	for i := 2; n.taken(name, host); i++ {
+		name = base + "-" + strconv.Itoa(i)
+	}
+	n.owners[name] = host
+	return name
+}
+
+// taken reports whether name was handed out to, or is the base name of,
+// a device other than host.
+func (n *Namer) taken(name, host string) bool {
+	if owner, ok := n.owners[name]; ok && owner != host {
+		return true
+	}
+	for other := range n.claims[name] {
+		if other != host {
+			return true
+		}
+	}
+	return false
+}
+
+func baseName(identity, host string) string {
+	if name := SanitizeFilename(identity); name != "" {
+		return name
+	}
+	return SanitizeFilename(host)
+}
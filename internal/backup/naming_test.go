@@ -0,0 +1,130 @@
+package backup_test
+
+import (
+	"testing"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+func TestParseIdentity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		output string
+		want   string
+		wantOK bool
+	}{
+		{name: "plain", output: "  name: core-router\n", want: "core-router", wantOK: true},
+		{name: "quoted with spaces", output: "  name: \"Branch Office #2\"\n", want: "Branch Office #2", wantOK: true},
+		{name: "missing", output: "\n", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := backup.ParseIdentity(tt.output)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseIdentity() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "core-router", want: "core-router"},
+		{input: "Branch Office #2", want: "Branch_Office__2"},
+		{input: "../../etc/passwd", want: "_.._etc_passwd"},
+		{input: "fe80::1", want: "fe80__1"},
+		{input: "  ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+
+			if got := backup.SanitizeFilename(tt.input); got != tt.want {
+				t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamer_Name(t *testing.T) {
+	t.Parallel()
+
+	type device struct {
+		identity string
+		host     string
+	}
+	devices := []device{
+		{identity: "core router", host: "10.0.0.1"},
+		{identity: "edge", host: "10.0.0.2"},
+		{identity: "core router", host: "10.0.0.3"},
+		{identity: "", host: "router.example.com"},
+		// Literally the name the duplicate on 10.0.0.3 would get.
+		{identity: "core router-10.0.0.3", host: "10.0.0.9"},
+	}
+	want := map[string]string{
+		"10.0.0.1":           "core_router-10.0.0.1",
+		"10.0.0.2":           "edge",
+		"10.0.0.3":           "core_router-10.0.0.3-2",
+		"router.example.com": "router.example.com",
+		"10.0.0.9":           "core_router-10.0.0.3",
+	}
+
+	tests := []struct {
+		name  string
+		order []int
+	}{
+		{name: "listed order", order: []int{0, 1, 2, 3, 4}},
+		{name: "reversed order", order: []int{4, 3, 2, 1, 0}},
+		{name: "shuffled order", order: []int{2, 4, 0, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			namer := backup.NewNamer()
+			for _, i := range tt.order {
+				namer.Observe(devices[i].identity, devices[i].host)
+			}
+			for _, i := range tt.order {
+				d := devices[i]
+				if got := namer.Name(d.identity, d.host); got != want[d.host] {
+					t.Errorf("Name(%q, %q) = %q, want %q", d.identity, d.host, got, want[d.host])
+				}
+			}
+			// Naming a device again keeps its name.
+			if got := namer.Name("core router", "10.0.0.1"); got != want["10.0.0.1"] {
+				t.Errorf("second Name() = %q, want %q", got, want["10.0.0.1"])
+			}
+		})
+	}
+}
+
+func TestNamer_NameLateDuplicate(t *testing.T) {
+	t.Parallel()
+
+	namer := backup.NewNamer()
+	if got := namer.Name("core", "10.0.0.1"); got != "core" {
+		t.Errorf("Name() = %q, want %q", got, "core")
+	}
+
+	// Once another device turns up with the same identity, neither uses the
+	// bare name, so the second device cannot take over the first one's file.
+	if got := namer.Name("core", "10.0.0.2"); got != "core-10.0.0.2" {
+		t.Errorf("Name() for duplicate = %q, want %q", got, "core-10.0.0.2")
+	}
+	if got := namer.Name("core", "10.0.0.1"); got != "core-10.0.0.1" {
+		t.Errorf("Name() after duplicate = %q, want %q", got, "core-10.0.0.1")
+	}
+}
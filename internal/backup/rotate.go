@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimestampLayout is the layout of the timestamp in rotated backup names.
+const TimestampLayout = "20060102-150405"
+
+const backupExt = ".rsc"
+
+// Sidecar file suffixes appended to a backup path.
+const (
+	MetadataExt = ".meta.json"
+	RoutesExt   = ".routes.json"
+)
+
+// ErrUnsafeRotation is returned when a rotation would delete every backup.
+var ErrUnsafeRotation = errors.New("unsafe rotation")
+
+// TimestampedName returns the file name for a backup of base taken at t,
+// e.g. "core-router-20240105-102030.rsc".
+func TimestampedName(base string, t time.Time) string {
+	return base + "-" + t.UTC().Format(TimestampLayout) + backupExt
+}
+
+type rotatedFile struct {
+	name  string
+	taken time.Time
+}
+
+// Rotate deletes the oldest backups of base in dir so that at most keep
+// remain, and returns the paths it removed.
+//
+// Only regular files named exactly as TimestampedName would name them are
+// considered; anything else sharing the directory is left alone. Rotate
+// refuses to run with keep below one. The backup just written, named by
+// current, and the backup with the newest timestamp are always kept. They
+// are usually the same file; if the clock went backwards they differ and
+// both survive, even when that leaves more than keep. A deleted backup's
+// sidecar files are removed with it.
+func Rotate(dir, base, current string, keep int) ([]string, error) {
+	if keep < 1 {
+		return nil, fmt.Errorf("%w: keep must be at least 1, got %d", ErrUnsafeRotation, keep)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var files []rotatedFile
+	var currentTaken time.Time
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		taken, ok := parseTimestampedName(entry.Name(), base)
+		if entry.Name() == current {
+			currentTaken = taken
+			keep--
+			continue
+		}
+		if ok {
+			files = append(files, rotatedFile{name: entry.Name(), taken: taken})
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].taken.Equal(files[j].taken) {
+			return files[i].taken.After(files[j].taken)
+		}
+		return files[i].name > files[j].name
+	})
+
+	// Keep the newest by timestamp even when current used up keep.
+	if files[0].taken.After(currentTaken) {
+		keep = max(keep, 1)
+	}
+	if len(files) <= keep {
+		return nil, nil
+	}
+
+	var removed []string
+	for _, f := range files[keep:] {
+		path := filepath.Join(dir, f.name)
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		removed = append(removed, path)
+
+		for _, ext := range []string{MetadataExt, RoutesExt} {
+			if err := os.Remove(path + ext); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return removed, fmt.Errorf("failed to remove old sidecar: %w", err)
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// parseTimestampedName reports whether name is a rotated backup of base and
+// returns the time it was taken.
+func parseTimestampedName(name, base string) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(name, base+"-")
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, ok = strings.CutSuffix(stamp, backupExt)
+	if !ok || len(stamp) != len(TimestampLayout) {
+		return time.Time{}, false
+	}
+	taken, err := time.Parse(TimestampLayout, stamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return taken, true
+}
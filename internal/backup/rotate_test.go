@@ -0,0 +1,187 @@
+package backup_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestTimestampedName(t *testing.T) {
+	t.Parallel()
+
+	taken := time.Date(2024, time.January, 5, 10, 20, 30, 0, time.UTC)
+	if got, want := backup.TimestampedName("core-router", taken), "core-router-20240105-102030.rsc"; got != want {
+		t.Errorf("TimestampedName() = %q, want %q", got, want)
+	}
+}
+
+func TestRotate_KeepsNewest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"router-20240101-000000.rsc",
+		"router-20240101-000000.rsc.meta.json",
+		"router-20240101-000000.rsc.routes.json",
+		"router-20240102-000000.rsc",
+		"router-20240103-000000.rsc",
+	)
+
+	removed, err := backup.Rotate(dir, "router", "router-20240103-000000.rsc", 2)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	if want := []string{filepath.Join(dir, "router-20240101-000000.rsc")}; !slices.Equal(removed, want) {
+		t.Errorf("Rotate() removed = %q, want %q", removed, want)
+	}
+	if got, want := listFiles(t, dir), []string{"router-20240102-000000.rsc", "router-20240103-000000.rsc"}; !slices.Equal(got, want) {
+		t.Errorf("files after Rotate() = %q, want %q", got, want)
+	}
+}
+
+func TestRotate_RefusesToDeleteEverything(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, "router-20240101-000000.rsc", "router-20240102-000000.rsc")
+
+	for _, keep := range []int{0, -1} {
+		_, err := backup.Rotate(dir, "router", "router-20240102-000000.rsc", keep)
+		if !errors.Is(err, backup.ErrUnsafeRotation) {
+			t.Errorf("Rotate(keep=%d) error = %v, want ErrUnsafeRotation", keep, err)
+		}
+	}
+
+	if got := listFiles(t, dir); len(got) != 2 {
+		t.Errorf("files after refused Rotate() = %q, want both kept", got)
+	}
+}
+
+func TestRotate_OnlyBackupIsKept(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, "router-20240101-000000.rsc")
+
+	removed, err := backup.Rotate(dir, "router", "router-20240101-000000.rsc", 1)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("Rotate() removed = %q, want nothing", removed)
+	}
+}
+
+func TestRotate_NewestIsDecidedByTimestampNotModTime(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, "router-20240103-000000.rsc", "router-20240101-000000.rsc")
+
+	// Make the newest backup look oldest on disk.
+	old := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "router-20240103-000000.rsc"), old, old); err != nil {
+		t.Fatalf("Chtimes() error = %v", err)
+	}
+
+	if _, err := backup.Rotate(dir, "router", "", 1); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if got, want := listFiles(t, dir), []string{"router-20240103-000000.rsc"}; !slices.Equal(got, want) {
+		t.Errorf("files after Rotate() = %q, want %q", got, want)
+	}
+}
+
+func TestRotate_KeepsCurrentAndNewestAfterClockStep(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	// The clock stepped back a day after the earlier backups were taken.
+	writeFiles(t, dir,
+		"router-20240102-000000.rsc",
+		"router-20240103-000000.rsc",
+		"router-20240101-120000.rsc",
+		"router-20240101-120000.rsc.meta.json",
+	)
+
+	removed, err := backup.Rotate(dir, "router", "router-20240101-120000.rsc", 1)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	if want := []string{filepath.Join(dir, "router-20240102-000000.rsc")}; !slices.Equal(removed, want) {
+		t.Errorf("Rotate() removed = %q, want %q", removed, want)
+	}
+	want := []string{"router-20240101-120000.rsc", "router-20240101-120000.rsc.meta.json", "router-20240103-000000.rsc"}
+	if got := listFiles(t, dir); !slices.Equal(got, want) {
+		t.Errorf("files after Rotate() = %q, want %q", got, want)
+	}
+}
+
+func TestRotate_SkipsUnrelatedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	unrelated := []string{
+		"notes.txt",
+		"router-backup.rsc",
+		"router-latest.rsc",
+		"router-2-20230101-000000.rsc",
+		"router-20230101-000000.rsc.bak",
+		"router-2023-01-01.rsc",
+		"router-99999999-999999.rsc",
+		"router2-20230101-000000.rsc",
+		"edge-20230101-000000.rsc",
+	}
+	writeFiles(t, dir, unrelated...)
+	writeFiles(t, dir, "router-20240101-000000.rsc", "router-20240102-000000.rsc")
+	if err := os.Mkdir(filepath.Join(dir, "router-20220101-000000.rsc"), 0o750); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+
+	removed, err := backup.Rotate(dir, "router", "router-20240102-000000.rsc", 1)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+
+	if want := []string{filepath.Join(dir, "router-20240101-000000.rsc")}; !slices.Equal(removed, want) {
+		t.Errorf("Rotate() removed = %q, want %q", removed, want)
+	}
+
+	remaining := listFiles(t, dir)
+	for _, name := range append(unrelated, "router-20220101-000000.rsc", "router-20240102-000000.rsc") {
+		if !slices.Contains(remaining, name) {
+			t.Errorf("Rotate() deleted %q", name)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Commands used to capture the routing tables. The terse form prints one
+// route per line as key=value pairs on both RouterOS v6 and v7.
+const (
+	ipv4RoutesCommand = "/ip route print terse"
+	ipv6RoutesCommand = "/ipv6 route print terse"
+)
+
+// Route is a single entry of a device routing table.
+type Route struct {
+	Destination string `json:"destination"`
+	Gateway     string `json:"gateway,omitempty"`
+	Distance    int    `json:"distance,omitempty"`
+	Active      bool   `json:"active"`
+	Dynamic     bool   `json:"dynamic,omitempty"`
+	Connected   bool   `json:"connected,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+// Routes holds the captured routing tables.
+type Routes struct {
+	IPv4 []Route `json:"ipv4"`
+	IPv6 []Route `json:"ipv6,omitempty"`
+	// Skipped describes the entries that could not be parsed, so the audit
+	// trail shows that a table is incomplete.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+var (
+	routeEntryPattern = regexp.MustCompile(`^\s*\d+\s`)
+	routeAttrPattern  = regexp.MustCompile(`(?:^|\s)([a-z][a-z0-9-]*)=`)
+)
+
+// ParseRoutes parses "/ip route print terse" or "/ipv6 route print terse"
+// output. Dynamic and connected routes are dropped unless includeDynamic
+// is set. Flags are interpreted for both v6 (C, S) and v7 (c, s) letters;
+// D always marks dynamic and A active routes. Entries continued on the
+// following lines, as after a ";;; comment", are joined first. Entries that
+// cannot be parsed are left out and described in skipped.
+func ParseRoutes(output string, includeDynamic bool) (routes []Route, skipped []string) {
+	var entries []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case routeEntryPattern.MatchString(line):
+			entries = append(entries, line)
+		case len(entries) > 0 && strings.TrimSpace(line) != "":
+			entries[len(entries)-1] += " " + strings.TrimSpace(line)
+		}
+	}
+
+	for _, entry := range entries {
+		route, err := parseRoute(entry)
+		if err != nil {
+			skipped = append(skipped, err.Error())
+			continue
+		}
+		if !includeDynamic && (route.Dynamic || route.Connected) {
+			continue
+		}
+		routes = append(routes, route)
+	}
+
+	return routes, skipped
+}
+
+func parseRoute(entry string) (Route, error) {
+	rest := strings.TrimLeft(strings.TrimSpace(entry), "0123456789")
+
+	head := rest
+	var attrs map[string]string
+	if m := routeAttrPattern.FindStringIndex(rest); m != nil {
+		head = rest[:m[0]]
+		attrs = parseAttributes(rest[m[0]:])
+	}
+	flags, comment, _ := strings.Cut(head, ";;;")
+
+	route := Route{
+		Destination: attrs["dst-address"],
+		Gateway:     attrs["gateway"],
+		Comment:     strings.TrimSpace(comment),
+		Active:      strings.Contains(flags, "A"),
+		Dynamic:     strings.Contains(flags, "D"),
+		Connected:   strings.ContainsAny(flags, "Cc"),
+		Disabled:    strings.Contains(flags, "X"),
+	}
+	if c, ok := attrs["comment"]; ok {
+		route.Comment = c
+	}
+	if route.Destination == "" {
+		return Route{}, fmt.Errorf("route without dst-address: %q", entry)
+	}
+	if d, ok := attrs["distance"]; ok {
+		distance, err := strconv.Atoi(d)
+		if err != nil {
+			return Route{}, fmt.Errorf("invalid distance in route %q: %w", entry, err)
+		}
+		route.Distance = distance
+	}
+
+	return route, nil
+}
+
+// parseAttributes splits "key=value key2=value two" into a map. Values run
+// until the next key, so unquoted values containing spaces are preserved.
+func parseAttributes(s string) map[string]string {
+	attrs := map[string]string{}
+	matches := routeAttrPattern.FindAllStringSubmatchIndex(s, -1)
+	for i, m := range matches {
+		end := len(s)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		attrs[s[m[2]:m[3]]] = strings.Trim(strings.TrimSpace(s[m[1]:end]), `"`)
+	}
+	return attrs
+}
+
+// collectRoutes captures the routing tables requested by config. Routes
+// that cannot be parsed are recorded in Routes.Skipped rather than failing
+// the backup, as the tables are only an addition to the export.
+func (s *Service) collectRoutes(ctx context.Context, config Config) (*Routes, error) {
+	routes := &Routes{}
+
+	output, err := s.sshClient.ExecuteCommand(ctx, ipv4RoutesCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IPv4 routes: %w", err)
+	}
+	var skipped []string
+	routes.IPv4, skipped = ParseRoutes(output, config.IncludeDynamicRoutes)
+	routes.Skipped = append(routes.Skipped, skipped...)
+
+	if config.IncludeIPv6Routes {
+		output, err := s.sshClient.ExecuteCommand(ctx, ipv6RoutesCommand)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read IPv6 routes: %w", err)
+		}
+		routes.IPv6, skipped = ParseRoutes(output, config.IncludeDynamicRoutes)
+		routes.Skipped = append(routes.Skipped, skipped...)
+	}
+
+	return routes, nil
+}
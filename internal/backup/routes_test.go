@@ -0,0 +1,173 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+const routerOS6Routes = ` 0 ADS  dst-address=0.0.0.0/0 gateway=192.168.1.1 gateway-status=192.168.1.1 reachable via  ether1 distance=1 scope=30 target-scope=10 vrf-interface=ether1
+ 1 A S  dst-address=10.10.0.0/16 gateway=10.0.0.254 gateway-status=10.0.0.254 reachable via  bridge1 distance=5 scope=30 target-scope=10
+ 2 ADC  dst-address=10.0.0.0/24 pref-src=10.0.0.1 gateway=bridge1 gateway-status=bridge1 reachable distance=0 scope=10
+ 3 X S  ;;; backup uplink
+        dst-address=0.0.0.0/0 gateway=172.16.0.1 distance=10
+ 4  S  dst-address=192.168.50.0/24 gateway=10.0.0.253 distance=1 comment="branch office"
+`
+
+const routerOS7Routes = `0  As  dst-address=0.0.0.0/0 routing-table=main gateway=192.168.88.1 immediate-gw=192.168.88.1%ether1 distance=1 scope=30 target-scope=10 suppress-hw-offload=no
+1  ADd dst-address=100.64.0.0/10 routing-table=main gateway=100.64.0.1 distance=1
+2  DAc dst-address=192.168.88.0/24 routing-table=main gateway=bridge local-address=192.168.88.1%bridge distance=0
+`
+
+func TestParseRoutes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		output         string
+		includeDynamic bool
+		want           []backup.Route
+	}{
+		{
+			name:   "routeros 6 static only",
+			output: routerOS6Routes,
+			want: []backup.Route{
+				{Destination: "10.10.0.0/16", Gateway: "10.0.0.254", Distance: 5, Active: true},
+				{Destination: "0.0.0.0/0", Gateway: "172.16.0.1", Distance: 10, Disabled: true, Comment: "backup uplink"},
+				{Destination: "192.168.50.0/24", Gateway: "10.0.0.253", Distance: 1, Comment: "branch office"},
+			},
+		},
+		{
+			name:           "routeros 6 with dynamic",
+			output:         routerOS6Routes,
+			includeDynamic: true,
+			want: []backup.Route{
+				{Destination: "0.0.0.0/0", Gateway: "192.168.1.1", Distance: 1, Active: true, Dynamic: true},
+				{Destination: "10.10.0.0/16", Gateway: "10.0.0.254", Distance: 5, Active: true},
+				{Destination: "10.0.0.0/24", Gateway: "bridge1", Active: true, Dynamic: true, Connected: true},
+				{Destination: "0.0.0.0/0", Gateway: "172.16.0.1", Distance: 10, Disabled: true, Comment: "backup uplink"},
+				{Destination: "192.168.50.0/24", Gateway: "10.0.0.253", Distance: 1, Comment: "branch office"},
+			},
+		},
+		{
+			name:   "routeros 7 static only",
+			output: routerOS7Routes,
+			want: []backup.Route{
+				{Destination: "0.0.0.0/0", Gateway: "192.168.88.1", Distance: 1, Active: true},
+			},
+		},
+		{
+			name:           "routeros 7 with dynamic",
+			output:         routerOS7Routes,
+			includeDynamic: true,
+			want: []backup.Route{
+				{Destination: "0.0.0.0/0", Gateway: "192.168.88.1", Distance: 1, Active: true},
+				{Destination: "100.64.0.0/10", Gateway: "100.64.0.1", Distance: 1, Active: true, Dynamic: true},
+				{Destination: "192.168.88.0/24", Gateway: "bridge", Active: true, Dynamic: true, Connected: true},
+			},
+		},
+		{
+			name:   "empty",
+			output: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, skipped := backup.ParseRoutes(tt.output, tt.includeDynamic)
+			if len(skipped) != 0 {
+				t.Fatalf("ParseRoutes() skipped = %q, want none", skipped)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRoutes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRoutes_SkipsUnparsable(t *testing.T) {
+	t.Parallel()
+
+	output := ` 0 A S  dst-address=10.0.0.0/8 gateway=10.0.0.1 distance=far
+ 1 A S  gateway=10.0.0.2 distance=1
+ 2 A S  dst-address=0.0.0.0/0 gateway=10.0.0.3 distance=1
+`
+	got, skipped := backup.ParseRoutes(output, false)
+
+	want := []backup.Route{{Destination: "0.0.0.0/0", Gateway: "10.0.0.3", Distance: 1, Active: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRoutes() = %+v, want %+v", got, want)
+	}
+	if len(skipped) != 2 || !strings.Contains(skipped[0], "distance=far") || !strings.Contains(skipped[1], "gateway=10.0.0.2") {
+		t.Errorf("ParseRoutes() skipped = %q, want the two broken entries", skipped)
+	}
+}
+
+func TestService_Execute_IncludeRoutes(t *testing.T) {
+	t.Parallel()
+
+	client := &mockSSHClient{
+		executeCommandFunc: func(_ context.Context, cmd string) (string, error) {
+			switch cmd {
+			case "/ip route print terse":
+				return routerOS7Routes, nil
+			case "/ipv6 route print terse":
+				return "0  As  dst-address=::/0 gateway=fe80::1%ether1 distance=1\n", nil
+			default:
+				return "", nil
+			}
+		},
+	}
+
+	service := backup.New(client)
+	config := backup.Config{Host: "192.168.88.1", IncludeRoutes: true, IncludeIPv6Routes: true}
+
+	meta, err := service.Execute(context.Background(), config, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v, want nil", err)
+	}
+
+	want := &backup.Routes{
+		IPv4: []backup.Route{{Destination: "0.0.0.0/0", Gateway: "192.168.88.1", Distance: 1, Active: true}},
+		IPv6: []backup.Route{{Destination: "::/0", Gateway: "fe80::1%ether1", Distance: 1, Active: true}},
+	}
+	if !reflect.DeepEqual(meta.Routes, want) {
+		t.Errorf("Execute() routes = %+v, want %+v", meta.Routes, want)
+	}
+}
+
+func TestService_Execute_UnparsableRoutesKeepBackup(t *testing.T) {
+	t.Parallel()
+
+	client := &mockSSHClient{
+		executeCommandFunc: func(_ context.Context, cmd string) (string, error) {
+			switch cmd {
+			case "/export":
+				return "/interface print\n", nil
+			case "/ip route print terse":
+				return "0  As  dst-address=0.0.0.0/0 gateway=192.168.88.1 distance=one\n", nil
+			default:
+				return "", nil
+			}
+		},
+	}
+
+	output := &bytes.Buffer{}
+	config := backup.Config{Host: "192.168.88.1", IncludeRoutes: true}
+	meta, err := backup.New(client).Execute(context.Background(), config, output)
+	if err != nil {
+		t.Fatalf("Execute() error = %v, want nil", err)
+	}
+	if output.String() != "/interface print\n" {
+		t.Errorf("Execute() output = %q, want the export", output.String())
+	}
+	if meta.Routes == nil || len(meta.Routes.IPv4) != 0 || len(meta.Routes.Skipped) != 1 {
+		t.Errorf("Execute() routes = %+v, want the broken route skipped", meta.Routes)
+	}
+}
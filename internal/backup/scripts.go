@@ -0,0 +1,190 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// scriptsCommand lists the scripts with their sources. Print detail shows
+// the source as the last attribute, continued on indented lines.
+const scriptsCommand = "/system script print detail"
+
+// Script is a single "/system script" entry.
+type Script struct {
+	Name   string
+	Source string
+}
+
+var (
+	// Script entries start with their index; source lines are indented
+	// further than any index column RouterOS prints.
+	scriptEntryPattern  = regexp.MustCompile(`^\s{0,3}\d+\s`)
+	scriptNamePattern   = regexp.MustCompile(`(?:^|\s)name=("(?:[^"\\]|\\.)*"|\S+)`)
+	scriptSourcePattern = regexp.MustCompile(`(?:^|\s)source=`)
+)
+
+// ParseScripts parses "/system script print detail" output. Entries without
+// a name are skipped. Source lines are returned without the indentation
+// RouterOS adds when printing them.
+func ParseScripts(output string) []Script {
+	var entries [][]string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case scriptEntryPattern.MatchString(line):
+			entries = append(entries, []string{line})
+		case len(entries) > 0:
+			entries[len(entries)-1] = append(entries[len(entries)-1], line)
+		}
+	}
+
+	var scripts []Script
+	for _, lines := range entries {
+		if script, ok := parseScript(strings.Join(lines, "\n")); ok {
+			scripts = append(scripts, script)
+		}
+	}
+	return scripts
+}
+
+func parseScript(entry string) (Script, bool) {
+	header, body := entry, ""
+	if m := scriptSourcePattern.FindStringIndex(entry); m != nil {
+		header, body = entry[:m[0]], entry[m[1]:]
+	}
+
+	m := scriptNamePattern.FindStringSubmatch(header)
+	if m == nil {
+		return Script{}, false
+	}
+	name := unquoteValue(m[1])
+	if name == "" {
+		return Script{}, false
+	}
+
+	inline, rest, _ := strings.Cut(body, "\n")
+	var lines []string
+	if inline = strings.TrimSpace(inline); inline != "" {
+		lines = append(lines, inline)
+	}
+	lines = append(lines, dedent(strings.Split(rest, "\n"))...)
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return Script{Name: name, Source: strings.Join(lines, "\n")}, true
+}
+
+// unquoteValue strips the quotes RouterOS prints around values containing
+// spaces or special characters and resolves its backslash escapes.
+func unquoteValue(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// dedent removes the indentation shared by all non-blank lines.
+func dedent(lines []string) []string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			line = line[indent:]
+		}
+		out[i] = strings.TrimRight(line, " \t")
+	}
+	return out
+}
+
+// ScriptFileNames returns a unique file name for each script, in order.
+// Names are sanitized with SanitizeFilename and compared case-insensitively
+// so they stay distinct on case-insensitive filesystems; later duplicates
+// get a numeric suffix.
+func ScriptFileNames(scripts []Script) []string {
+	used := map[string]bool{}
+	names := make([]string, len(scripts))
+	for i, script := range scripts {
+		base := SanitizeFilename(script.Name)
+		if base == "" {
+			base = "script"
+		}
+		name := base
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			name = base + "-" + strconv.Itoa(n)
+		}
+		used[strings.ToLower(name)] = true
+		names[i] = name + backupExt
+	}
+	return names
+}
+
+// WriteScripts writes each script source to its own file in dir, which must
+// exist, and removes script files left over from scripts that no longer
+// exist on the device. It returns the paths written.
+func WriteScripts(dir string, scripts []Script, perm os.FileMode, owner *Owner) ([]string, error) {
+	names := ScriptFileNames(scripts)
+	keep := map[string]bool{}
+	paths := make([]string, 0, len(scripts))
+	for i, script := range scripts {
+		path := filepath.Join(dir, names[i])
+		if err := WriteFileAtomic(path, []byte(script.Source+"\n"), perm, owner); err != nil {
+			return paths, fmt.Errorf("failed to write script %q: %w", script.Name, err)
+		}
+		keep[names[i]] = true
+		paths = append(paths, path)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return paths, fmt.Errorf("failed to list scripts directory: %w", err)
+	}
+	var errs []error
+	for _, entry := range entries {
+		name := entry.Name()
+		if keep[name] || !entry.Type().IsRegular() || filepath.Ext(name) != backupExt {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove stale script: %w", err))
+		}
+	}
+
+	return paths, errors.Join(errs...)
+}
+
+// collectScripts captures the device scripts.
+func (s *Service) collectScripts(ctx context.Context) ([]Script, error) {
+	output, err := s.sshClient.ExecuteCommand(ctx, scriptsCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scripts: %w", err)
+	}
+	return ParseScripts(output), nil
+}
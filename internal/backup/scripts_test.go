@@ -0,0 +1,140 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+const routerOSScripts = `Flags: I - invalid
+ 0   name="daily-backup" owner="admin" policy=ftp,reboot,read,write,policy,test,password,sniff,sensitive,romon
+     dont-require-permissions=no run-count=12 last-started=2024-01-05 03:00:00 source=
+       /system backup save name=daily
+       :if ([/file find name="daily.backup"] != "") do={
+           :log info "backup done"
+       }
+
+ 1   name="say hello" owner="admin" policy=read dont-require-permissions=no run-count=0 source=:put "hello"
+
+ 2 I ;;; broken on purpose
+     name="Daily-Backup" owner="admin" policy=read dont-require-permissions=no run-count=0 source=
+       :put [/nonexistent get]
+
+ 3   name="../etc/passwd" owner="admin" policy=read dont-require-permissions=no run-count=0 source=
+`
+
+func TestParseScripts(t *testing.T) {
+	t.Parallel()
+
+	got := backup.ParseScripts(routerOSScripts)
+	want := []backup.Script{
+		{
+			Name: "daily-backup",
+			Source: "/system backup save name=daily\n" +
+				":if ([/file find name=\"daily.backup\"] != \"\") do={\n" +
+				"    :log info \"backup done\"\n" +
+				"}",
+		},
+		{Name: "say hello", Source: `:put "hello"`},
+		{Name: "Daily-Backup", Source: ":put [/nonexistent get]"},
+		{Name: "../etc/passwd", Source: ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseScripts() = %q, want %q", got, want)
+	}
+}
+
+func TestParseScripts_Empty(t *testing.T) {
+	t.Parallel()
+
+	if got := backup.ParseScripts("Flags: I - invalid\n"); got != nil {
+		t.Errorf("ParseScripts() = %q, want nil", got)
+	}
+}
+
+func TestScriptFileNames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		scripts []string
+		want    []string
+	}{
+		{name: "plain", scripts: []string{"backup", "cleanup"}, want: []string{"backup.rsc", "cleanup.rsc"}},
+		{name: "sanitized", scripts: []string{"say hello", "../etc/passwd"}, want: []string{"say_hello.rsc", "_etc_passwd.rsc"}},
+		{name: "empty after sanitizing", scripts: []string{"..."}, want: []string{"script.rsc"}},
+		{name: "case-insensitive collision", scripts: []string{"Backup", "backup"}, want: []string{"Backup.rsc", "backup-2.rsc"}},
+		{name: "collision after sanitizing", scripts: []string{"a b", "a/b", "a_b-2"}, want: []string{"a_b.rsc", "a_b-2.rsc", "a_b-2-2.rsc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			scripts := make([]backup.Script, len(tt.scripts))
+			for i, name := range tt.scripts {
+				scripts[i] = backup.Script{Name: name}
+			}
+			if got := backup.ScriptFileNames(scripts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScriptFileNames() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteScripts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, "removed-script.rsc", "notes.txt")
+
+	paths, err := backup.WriteScripts(dir, backup.ParseScripts(routerOSScripts), 0o600, nil)
+	if err != nil {
+		t.Fatalf("WriteScripts() error = %v", err)
+	}
+	if len(paths) != 4 {
+		t.Errorf("WriteScripts() wrote %d files, want 4", len(paths))
+	}
+
+	want := []string{"Daily-Backup-2.rsc", "_etc_passwd.rsc", "daily-backup.rsc", "notes.txt", "say_hello.rsc"}
+	if got := listFiles(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("files after WriteScripts() = %q, want %q", got, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "say_hello.rsc"))
+	if err != nil {
+		t.Fatalf("failed to read script: %v", err)
+	}
+	if string(data) != ":put \"hello\"\n" {
+		t.Errorf("script content = %q, want %q", data, ":put \"hello\"\n")
+	}
+}
+
+func TestService_Execute_IncludeScripts(t *testing.T) {
+	t.Parallel()
+
+	client := &mockSSHClient{
+		executeCommandFunc: func(_ context.Context, cmd string) (string, error) {
+			if cmd == "/system script print detail" {
+				return routerOSScripts, nil
+			}
+			return "", nil
+		},
+	}
+
+	service := backup.New(client)
+	config := backup.Config{Host: "192.168.88.1", IncludeScripts: true}
+
+	meta, err := service.Execute(context.Background(), config, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Execute() error = %v, want nil", err)
+	}
+	if !reflect.DeepEqual(meta.Scripts, backup.ParseScripts(routerOSScripts)) {
+		t.Errorf("Execute() scripts = %q, want the parsed sample", meta.Scripts)
+	}
+}
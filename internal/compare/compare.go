@@ -0,0 +1,287 @@
+// Package compare detects configuration drift between MikroTik devices.
+package compare
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+// Op identifies which side of a comparison a line belongs to.
+type Op int
+
+// Diff operations.
+const (
+	OpEqual Op = iota
+	OpDelete
+	OpInsert
+)
+
+// Line is a single entry of a diff.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Exporter exports the configuration of a device.
+type Exporter interface {
+	Execute(ctx context.Context, config backup.Config, output io.Writer) (backup.Metadata, error)
+}
+
+// Device pairs an exporter with the configuration used to reach it.
+type Device struct {
+	Exporter Exporter
+	Config   backup.Config
+}
+
+// DefaultIgnore returns the patterns for fields expected to differ between
+// otherwise identical devices.
+func DefaultIgnore() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		regexp.MustCompile(`^/system identity set name=`),
+	}
+}
+
+// Devices exports a and b, normalizes both configurations, drops lines
+// matching any ignore pattern and returns the lines that differ.
+func Devices(ctx context.Context, a, b Device, ignore []*regexp.Regexp) ([]Line, error) {
+	left, err := export(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	right, err := export(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return Changes(Diff(Filter(Normalize(left), ignore), Filter(Normalize(right), ignore))), nil
+}
+
+func export(ctx context.Context, d Device) (string, error) {
+	var buf bytes.Buffer
+	if _, err := d.Exporter.Execute(ctx, d.Config, &buf); err != nil {
+		return "", fmt.Errorf("failed to export %s: %w", d.Config.Host, err)
+	}
+	return buf.String(), nil
+}
+
+// Normalize converts a RouterOS export into one self-contained line per
+// command. Comments are dropped, continuation lines are joined and each
+// command is prefixed with the menu path it belongs to, e.g.
+// "/ip address add address=10.0.0.1/24 interface=ether1".
+func Normalize(export string) []string {
+	var lines []string
+	section := ""
+	pending := ""
+
+	for _, raw := range strings.Split(strings.ReplaceAll(export, "\r\n", "\n"), "\n") {
+		line := strings.TrimRight(raw, " \t")
+		if pending != "" {
+			line = pending + strings.TrimLeft(line, " \t")
+			pending = ""
+		}
+		if strings.HasSuffix(line, `\`) {
+			pending = strings.TrimSuffix(line, `\`)
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "/") && !strings.Contains(line, "="):
+			section = line
+			continue
+		case strings.HasPrefix(line, "/"), section == "":
+			lines = append(lines, line)
+		default:
+			lines = append(lines, section+" "+line)
+		}
+	}
+	if pending = strings.TrimSpace(pending); pending != "" {
+		lines = append(lines, pending)
+	}
+
+	return lines
+}
+
+// Filter returns lines without those matching any of the patterns.
+func Filter(lines []string, patterns []*regexp.Regexp) []string {
+	kept := make([]string, 0, len(lines))
+next:
+	for _, line := range lines {
+		for _, p := range patterns {
+			if p.MatchString(line) {
+				continue next
+			}
+		}
+		kept = append(kept, line)
+	}
+	return kept
+}
+
+// Changes returns only the inserted and deleted lines of diff.
+func Changes(diff []Line) []Line {
+	var changes []Line
+	for _, l := range diff {
+		if l.Op != OpEqual {
+			changes = append(changes, l)
+		}
+	}
+	return changes
+}
+
+// Diff computes a minimal line diff turning a into b using the linear-space
+// variant of Myers' algorithm, so memory stays proportional to the input
+// even when a and b have little in common.
+func Diff(a, b []string) []Line {
+	var out []Line
+	diff(&out, a, b)
+	return out
+}
+
+// diff appends the diff of a and b to out. Common leading and trailing lines
+// are emitted directly; the rest is split at the middle of an optimal edit
+// path and each half diffed recursively.
+func diff(out *[]Line, a, b []string) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	emit(out, OpEqual, a[:prefix])
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	if x, y, ok := middle(a, b); ok {
+		diff(out, a[:x], b[:y])
+		diff(out, a[x:], b[y:])
+	} else {
+		emit(out, OpDelete, a)
+		emit(out, OpInsert, b)
+	}
+
+	emit(out, OpEqual, common)
+}
+
+// middle searches forward from the start and backward from the end of a
+// and b at the same time and returns the point where the two searches
+// first overlap, which lies on an optimal edit path. It reports false when
+// either side is empty or a and b have no line in common.
+func middle(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+
+	maxD := (n + m + 1) / 2 //nolint:mnd // each search covers half the edits
+	offset := maxD
+	forward := make([]int, 2*maxD+2)  //nolint:mnd // diagonals -maxD..maxD+1
+	backward := make([]int, 2*maxD+2) //nolint:mnd // diagonals -maxD..maxD+1
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	// delta is the diagonal the forward search ends on, seen from the
+	// backward search. Its parity decides which search detects the overlap.
+	delta := n - m
+	odd := delta%2 != 0
+	// Diagonals whose path already left the edit graph are skipped.
+	var fStart, fEnd, bStart, bEnd int
+
+	for d := range maxD {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			x := step(forward, offset, k, d)
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			switch {
+			case x > n:
+				fEnd += 2
+			case y > m:
+				fStart += 2
+			case odd:
+				if bk := offset + delta - k; bk >= 0 && bk < len(backward) && backward[bk] != -1 && x >= n-backward[bk] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			x := step(backward, offset, k, d)
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			switch {
+			case x > n:
+				bEnd += 2
+			case y > m:
+				bStart += 2
+			case !odd:
+				if fk := offset + delta - k; fk >= 0 && fk < len(forward) && forward[fk] != -1 && forward[fk] >= n-x {
+					fx := forward[fk]
+					return fx, fx - (fk - offset), true
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// step returns the x reached on diagonal k after d edits, before following
+// the snake: one insertion from diagonal k+1 or one deletion from k-1,
+// whichever got further.
+func step(v []int, offset, k, d int) int {
+	if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+		return v[offset+k+1]
+	}
+	return v[offset+k-1] + 1
+}
+
+func emit(out *[]Line, op Op, lines []string) {
+	for _, text := range lines {
+		*out = append(*out, Line{Op: op, Text: text})
+	}
+}
+
+// Write prints changes in a unified-diff-like format labelled with the
+// names of both sides.
+func Write(w io.Writer, nameA, nameB string, changes []Line) error {
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", nameA, nameB); err != nil {
+		return fmt.Errorf("failed to write diff: %w", err)
+	}
+	for _, l := range changes {
+		prefix := " "
+		switch l.Op {
+		case OpDelete:
+			prefix = "-"
+		case OpInsert:
+			prefix = "+"
+		case OpEqual:
+		}
+		if _, err := fmt.Fprintf(w, "%s %s\n", prefix, l.Text); err != nil {
+			return fmt.Errorf("failed to write diff: %w", err)
+		}
+	}
+	return nil
+}
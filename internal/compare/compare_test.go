@@ -0,0 +1,283 @@
+package compare_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/compare"
+)
+
+// mockSSHClient is a mock implementation of backup.SSHClient that returns
+// a fixed export.
+type mockSSHClient struct {
+	export string
+	err    error
+}
+
+func (m *mockSSHClient) Connect(_ context.Context, _ backup.Config) error {
+	return nil
+}
+
+func (m *mockSSHClient) ExecuteCommand(_ context.Context, _ string) (string, error) {
+	return m.export, m.err
+}
+
+func (m *mockSSHClient) Close() error {
+	return nil
+}
+
+const primaryExport = `# 2024-01-05 10:20:30 by RouterOS 7.13.2
+# software id = AAAA-1111
+/interface bridge
+add name=bridge1
+/ip address
+add address=10.0.0.2/24 interface=bridge1
+/ip firewall filter
+add action=accept chain=input connection-state=established,related \
+    comment="allow established"
+add action=drop chain=input in-interface=ether1
+/system identity
+set name=router-a
+`
+
+const secondaryExport = `# 2024-01-05 10:21:02 by RouterOS 7.13.2
+# software id = BBBB-2222
+/interface bridge
+add name=bridge1
+/ip address
+add address=10.0.0.3/24 interface=bridge1
+/ip firewall filter
+add action=accept chain=input connection-state=established,related \
+    comment="allow established"
+add action=accept chain=input in-interface=ether1 protocol=icmp
+add action=drop chain=input in-interface=ether1
+/system identity
+set name=router-b
+`
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	want := []string{
+		"/interface bridge add name=bridge1",
+		"/ip address add address=10.0.0.2/24 interface=bridge1",
+		`/ip firewall filter add action=accept chain=input connection-state=established,related comment="allow established"`,
+		"/ip firewall filter add action=drop chain=input in-interface=ether1",
+		"/system identity set name=router-a",
+	}
+
+	if got := compare.Normalize(primaryExport); !reflect.DeepEqual(got, want) {
+		t.Errorf("Normalize() = %q, want %q", got, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a, b []string
+		want []compare.Line
+	}{
+		{
+			name: "identical",
+			a:    []string{"x", "y"},
+			b:    []string{"x", "y"},
+			want: []compare.Line{{Op: compare.OpEqual, Text: "x"}, {Op: compare.OpEqual, Text: "y"}},
+		},
+		{
+			name: "insert and delete",
+			a:    []string{"a", "b", "c"},
+			b:    []string{"a", "c", "d"},
+			want: []compare.Line{
+				{Op: compare.OpEqual, Text: "a"},
+				{Op: compare.OpDelete, Text: "b"},
+				{Op: compare.OpEqual, Text: "c"},
+				{Op: compare.OpInsert, Text: "d"},
+			},
+		},
+		{
+			name: "empty left",
+			b:    []string{"a"},
+			want: []compare.Line{{Op: compare.OpInsert, Text: "a"}},
+		},
+		{
+			name: "both empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := compare.Diff(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// checkDiff fails unless diff turns a into b with the given number of
+// inserted and deleted lines.
+func checkDiff(t *testing.T, a, b []string, diff []compare.Line, wantEdits int) {
+	t.Helper()
+
+	var gotA, gotB []string
+	edits := 0
+	for _, l := range diff {
+		switch l.Op {
+		case compare.OpEqual:
+			gotA = append(gotA, l.Text)
+			gotB = append(gotB, l.Text)
+		case compare.OpDelete:
+			gotA = append(gotA, l.Text)
+			edits++
+		case compare.OpInsert:
+			gotB = append(gotB, l.Text)
+			edits++
+		}
+	}
+	if !slices.Equal(gotA, a) || !slices.Equal(gotB, b) {
+		t.Fatalf("Diff(%q, %q) = %v does not turn a into b", a, b, diff)
+	}
+	if edits != wantEdits {
+		t.Errorf("Diff(%q, %q) has %d edits, want %d", a, b, edits, wantEdits)
+	}
+}
+
+// minEdits returns the minimal number of inserted and deleted lines turning
+// a into b, from the longest common subsequence.
+func minEdits(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return len(a) + len(b) - 2*lcs[0][0]
+}
+
+func TestDiff_Minimal(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewPCG(1, 2)) //nolint:gosec // reproducible test input
+	randomLines := func() []string {
+		lines := make([]string, rng.IntN(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.IntN(4)))
+		}
+		return lines
+	}
+
+	for range 500 {
+		a, b := randomLines(), randomLines()
+		checkDiff(t, a, b, compare.Diff(a, b), minEdits(a, b))
+	}
+}
+
+// TestDiff_LargeDissimilar is not parallel so that the allocations it
+// measures are its own.
+func TestDiff_LargeDissimilar(t *testing.T) {
+	const size = 5000
+
+	a := make([]string, size)
+	b := make([]string, size)
+	for i := range size {
+		a[i] = fmt.Sprintf("/ip address add address=10.0.%d.1/24", i)
+		b[i] = fmt.Sprintf("/ip address add address=10.1.%d.1/24", i)
+		if i%100 == 0 {
+			b[i] = a[i]
+		}
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	diff := compare.Diff(a, b)
+	runtime.ReadMemStats(&after)
+
+	checkDiff(t, a, b, diff, 2*(size-size/100))
+	// The edit path here is close to 2*size long; storing the search
+	// frontier for every edit would need gigabytes.
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Errorf("Diff() allocated %d MiB, want at most 64 MiB", allocated>>20)
+	}
+}
+
+func TestDevices(t *testing.T) {
+	t.Parallel()
+
+	a := compare.Device{
+		Exporter: backup.New(&mockSSHClient{export: primaryExport}),
+		Config:   backup.Config{Host: "10.0.0.2"},
+	}
+	b := compare.Device{
+		Exporter: backup.New(&mockSSHClient{export: secondaryExport}),
+		Config:   backup.Config{Host: "10.0.0.3"},
+	}
+	ignore := append(compare.DefaultIgnore(), regexp.MustCompile(`^/ip address add address=10\.0\.0\.`))
+
+	changes, err := compare.Devices(context.Background(), a, b, ignore)
+	if err != nil {
+		t.Fatalf("Devices() error = %v", err)
+	}
+
+	want := []compare.Line{
+		{Op: compare.OpInsert, Text: "/ip firewall filter add action=accept chain=input in-interface=ether1 protocol=icmp"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("Devices() = %v, want %v", changes, want)
+	}
+
+	var out bytes.Buffer
+	if err := compare.Write(&out, "10.0.0.2", "10.0.0.3", changes); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "+ /ip firewall filter add action=accept chain=input in-interface=ether1 protocol=icmp") {
+		t.Errorf("Write() output = %q, want inserted rule", out.String())
+	}
+}
+
+func TestDevices_Identical(t *testing.T) {
+	t.Parallel()
+
+	a := compare.Device{Exporter: backup.New(&mockSSHClient{export: primaryExport}), Config: backup.Config{Host: "a"}}
+	b := compare.Device{Exporter: backup.New(&mockSSHClient{export: primaryExport}), Config: backup.Config{Host: "b"}}
+
+	changes, err := compare.Devices(context.Background(), a, b, nil)
+	if err != nil {
+		t.Fatalf("Devices() error = %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Devices() = %v, want no changes", changes)
+	}
+}
+
+func TestDevices_ExportError(t *testing.T) {
+	t.Parallel()
+
+	expectedErr := errors.New("export failed")
+	a := compare.Device{Exporter: backup.New(&mockSSHClient{export: primaryExport}), Config: backup.Config{Host: "a"}}
+	b := compare.Device{Exporter: backup.New(&mockSSHClient{err: expectedErr}), Config: backup.Config{Host: "b"}}
+
+	_, err := compare.Devices(context.Background(), a, b, nil)
+	if !errors.Is(err, expectedErr) {
+		t.Errorf("Devices() error = %v, want %v", err, expectedErr)
+	}
+}
@@ -0,0 +1,134 @@
+// Package hook runs an external command for every device a batch completes.
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+// Status values passed to hooks in MIKROTIK_BACKUP_STATUS.
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// DefaultTimeout is how long a hook may run before it is killed.
+const DefaultTimeout = time.Minute
+
+// waitDelay bounds how long Run waits for the output of processes the hook
+// started once the hook itself has exited or been killed.
+const waitDelay = 5 * time.Second
+
+// envPrefix is the prefix of the variables describing a result. Inherited
+// variables with it, such as MIKROTIK_PASSWORD, are not passed on.
+const envPrefix = "MIKROTIK_"
+
+// Command is an external program invoked with device metadata. It receives
+// the host and backup path as arguments and the full result in MIKROTIK_*
+// environment variables.
+type Command struct {
+	path    string
+	output  io.Writer
+	timeout time.Duration
+}
+
+// Option configures a Command.
+type Option func(*Command)
+
+// WithTimeout replaces DefaultTimeout. A timeout of zero or less disables
+// it.
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *Command) {
+		h.timeout = timeout
+	}
+}
+
+// New creates a hook that runs the program at path, sending its standard
+// output and error to output.
+func New(path string, output io.Writer, opts ...Option) *Command {
+	h := &Command{
+		path:    path,
+		output:  output,
+		timeout: DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Run invokes the hook for result and waits for it to exit. The hook is
+// killed once its timeout expires, so a hung hook cannot stall the batch.
+func (h *Command) Run(ctx context.Context, result backup.DeviceResult) error {
+	env, err := Env(result)
+	if err != nil {
+		return err
+	}
+
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, h.path, result.Config.Host, result.Path) //nolint:gosec // hook path is provided by the operator
+	cmd.Env = append(inheritedEnv(), env...)
+	cmd.Stdout = h.output
+	cmd.Stderr = h.output
+	cmd.WaitDelay = waitDelay
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("hook %s timed out after %s: %w", h.path, h.timeout, ctx.Err())
+		}
+		return fmt.Errorf("hook %s failed: %w", h.path, err)
+	}
+	return nil
+}
+
+// inheritedEnv returns the environment of this process without its own
+// MIKROTIK_* configuration, which includes credentials.
+func inheritedEnv() []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envPrefix) {
+			env = append(env, kv)
+		}
+	}
+	return env
+}
+
+// Env returns the environment variables describing result.
+func Env(result backup.DeviceResult) ([]string, error) {
+	meta, err := json.Marshal(result.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	status := StatusSuccess
+	errText := ""
+	if result.Err != nil {
+		status = StatusFailure
+		errText = result.Err.Error()
+	}
+
+	return []string{
+		"MIKROTIK_HOST=" + result.Config.Host,
+		"MIKROTIK_PORT=" + strconv.Itoa(result.Config.Port),
+		"MIKROTIK_IDENTITY=" + result.Metadata.Identity,
+		"MIKROTIK_BACKUP_STATUS=" + status,
+		"MIKROTIK_BACKUP_PATH=" + result.Path,
+		"MIKROTIK_BACKUP_ERROR=" + errText,
+		"MIKROTIK_METADATA=" + string(meta),
+	}, nil
+}
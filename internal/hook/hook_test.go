@@ -0,0 +1,185 @@
+package hook_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/hook"
+)
+
+func TestEnv(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		result backup.DeviceResult
+		want   []string
+	}{
+		{
+			name: "success",
+			result: backup.DeviceResult{
+				Config:   backup.Config{Host: "10.0.0.1", Port: 22},
+				Metadata: backup.Metadata{Identity: "core-router"},
+				Path:     "/backups/core-router.rsc",
+			},
+			want: []string{
+				"MIKROTIK_HOST=10.0.0.1",
+				"MIKROTIK_PORT=22",
+				"MIKROTIK_IDENTITY=core-router",
+				"MIKROTIK_BACKUP_STATUS=success",
+				"MIKROTIK_BACKUP_PATH=/backups/core-router.rsc",
+				"MIKROTIK_BACKUP_ERROR=",
+				`MIKROTIK_METADATA={"identity":"core-router"}`,
+			},
+		},
+		{
+			name: "failure",
+			result: backup.DeviceResult{
+				Config: backup.Config{Host: "10.0.0.2", Port: 2222},
+				Err:    errors.New("connection refused"),
+			},
+			want: []string{
+				"MIKROTIK_HOST=10.0.0.2",
+				"MIKROTIK_PORT=2222",
+				"MIKROTIK_IDENTITY=",
+				"MIKROTIK_BACKUP_STATUS=failure",
+				"MIKROTIK_BACKUP_PATH=",
+				"MIKROTIK_BACKUP_ERROR=connection refused",
+				"MIKROTIK_METADATA={}",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := hook.Env(tt.result)
+			if err != nil {
+				t.Fatalf("Env() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Env() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommand_Run(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("hook script requires a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls.log")
+	script := filepath.Join(dir, "hook.sh")
+	body := "#!/bin/sh\necho \"$1 $2 $MIKROTIK_BACKUP_STATUS $MIKROTIK_IDENTITY\" >> " + log + "\n"
+	if err := os.WriteFile(script, []byte(body), 0o700); err != nil { //nolint:gosec // script must be executable
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	h := hook.New(script, &bytes.Buffer{})
+	results := []backup.DeviceResult{
+		{Config: backup.Config{Host: "10.0.0.1"}, Metadata: backup.Metadata{Identity: "a"}, Path: "/b/a.rsc"},
+		{Config: backup.Config{Host: "10.0.0.2"}, Err: errors.New("timeout")},
+	}
+	for _, result := range results {
+		if err := h.Run(context.Background(), result); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(log) //nolint:gosec // test file
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	want := "10.0.0.1 /b/a.rsc success a\n10.0.0.2  failure \n"
+	if got := string(data); got != want {
+		t.Errorf("hook calls = %q, want %q", got, want)
+	}
+}
+
+// writeScript writes an executable shell script with body to dir.
+func writeScript(t *testing.T, dir, body string) string {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("hook script requires a POSIX shell")
+	}
+	script := filepath.Join(dir, "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"+body), 0o700); err != nil { //nolint:gosec // script must be executable
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return script
+}
+
+func TestCommand_RunTimeout(t *testing.T) {
+	t.Parallel()
+
+	// The backgrounded sleep keeps the output pipe open after the shell is
+	// killed, like a hook that forked a daemon.
+	script := writeScript(t, t.TempDir(), "sleep 30 &\nsleep 30\n")
+	h := hook.New(script, &bytes.Buffer{}, hook.WithTimeout(100*time.Millisecond))
+
+	start := time.Now()
+	err := h.Run(context.Background(), backup.DeviceResult{Config: backup.Config{Host: "10.0.0.1"}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Run() error = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Run() returned after %v, want shortly after the timeout", elapsed)
+	}
+}
+
+func TestCommand_RunDropsInheritedConfig(t *testing.T) {
+	t.Setenv("MIKROTIK_PASSWORD", "s3cret")
+	t.Setenv("MIKROTIK_USERNAME", "backup")
+	t.Setenv("MIKROTIK_SOME_SETTING", "value")
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, "env.log")
+	script := writeScript(t, dir, "env > "+log+"\n")
+
+	h := hook.New(script, &bytes.Buffer{})
+	if err := h.Run(context.Background(), backup.DeviceResult{Config: backup.Config{Host: "10.0.0.1"}}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	data, err := os.ReadFile(log) //nolint:gosec // test file
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	env := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "MIKROTIK_PASSWORD=") || strings.HasPrefix(kv, "MIKROTIK_USERNAME=") || strings.HasPrefix(kv, "MIKROTIK_SOME_SETTING=") {
+			t.Errorf("hook inherited %q", kv)
+		}
+	}
+	if !slices.Contains(env, "MIKROTIK_HOST=10.0.0.1") {
+		t.Errorf("hook environment = %q, want MIKROTIK_HOST", env)
+	}
+	if os.Getenv("PATH") != "" && !slices.Contains(env, "PATH="+os.Getenv("PATH")) {
+		t.Error("hook did not inherit PATH")
+	}
+}
+
+func TestCommand_RunFailure(t *testing.T) {
+	t.Parallel()
+
+	h := hook.New(filepath.Join(t.TempDir(), "missing"), &bytes.Buffer{})
+	err := h.Run(context.Background(), backup.DeviceResult{Config: backup.Config{Host: "10.0.0.1"}})
+	if err == nil || !strings.Contains(err.Error(), "hook") {
+		t.Errorf("Run() error = %v, want hook failure", err)
+	}
+}
@@ -0,0 +1,198 @@
+// Package schedule plans recurring backups and persists each host's schedule
+// position so a restarted daemon resumes where it left off.
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+const stateFileMode = 0o600
+
+// HostState is the persisted schedule position of one host.
+type HostState struct {
+	LastRun time.Time `json:"last_run,omitzero"`
+	NextRun time.Time `json:"next_run"`
+	// Offset is the host's jitter offset, drawn once so hosts stay spread
+	// out across restarts.
+	Offset   time.Duration `json:"offset"`
+	Failures int           `json:"failures,omitempty"`
+}
+
+// State is the persisted schedule of every host, keyed by host.
+type State struct {
+	Hosts map[string]HostState `json:"hosts"`
+}
+
+// Load reads the state file at path. A missing file yields an empty state.
+func Load(path string) (State, error) {
+	state := State{Hosts: map[string]HostState{}}
+
+	data, err := os.ReadFile(path) //nolint:gosec // path is provided by the operator
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read schedule state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to decode schedule state %s: %w", path, err)
+	}
+	if state.Hosts == nil {
+		state.Hosts = map[string]HostState{}
+	}
+	return state, nil
+}
+
+// Save atomically replaces the state file at path, so a crash while saving
+// leaves the previous state intact.
+func Save(path string, state State, owner *backup.Owner) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schedule state: %w", err)
+	}
+	if err := backup.WriteFileAtomic(path, append(data, '\n'), stateFileMode, owner); err != nil {
+		return fmt.Errorf("failed to write schedule state: %w", err)
+	}
+	return nil
+}
+
+// Config controls how often hosts are backed up.
+type Config struct {
+	// Interval is the time between successful runs of a host.
+	Interval time.Duration
+	// Jitter bounds the offset each host is given so that hosts do not
+	// all run at the same moment.
+	Jitter time.Duration
+	// RetryBase is the first retry delay after a failure. It doubles with
+	// every consecutive failure, up to Interval.
+	RetryBase time.Duration
+}
+
+// Option configures a Scheduler.
+type Option func(*Scheduler)
+
+// WithRandom replaces the source of jitter. fn must return a value in
+// [0, n) for n > 0.
+func WithRandom(fn func(n time.Duration) time.Duration) Option {
+	return func(s *Scheduler) {
+		s.random = fn
+	}
+}
+
+// Scheduler decides when each host runs next.
+type Scheduler struct {
+	config Config
+	hosts  map[string]HostState
+	random func(n time.Duration) time.Duration
+}
+
+// New creates a scheduler starting from state, usually loaded from disk.
+// Call Resume before asking for the next run.
+func New(config Config, state State, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		config: config,
+		hosts:  map[string]HostState{},
+		random: func(n time.Duration) time.Duration {
+			return time.Duration(rand.Int64N(int64(n))) //nolint:gosec // jitter need not be unpredictable
+		},
+	}
+	for host, hs := range state.Hosts {
+		s.hosts[host] = hs
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Resume sets up the schedule of hosts at now. Hosts with a future next run
+// keep it. Overdue hosts, such as those missed while the daemon was down,
+// run after their jitter offset instead of all at once, as do hosts seen
+// for the first time. Hosts not listed are forgotten.
+func (s *Scheduler) Resume(hosts []string, now time.Time) {
+	resumed := make(map[string]HostState, len(hosts))
+	for _, host := range hosts {
+		hs, ok := s.hosts[host]
+		// Redraw offsets that no longer fit a changed --jitter.
+		if !ok || hs.Offset < 0 || (hs.Offset > 0 && hs.Offset >= s.config.Jitter) {
+			hs.Offset = s.jitter(s.config.Jitter)
+		}
+		if !hs.NextRun.After(now) {
+			hs.NextRun = now.Add(hs.Offset)
+		}
+		resumed[host] = hs
+	}
+	s.hosts = resumed
+}
+
+// Next returns the host due first and when it is due. It returns false when
+// no hosts are scheduled.
+func (s *Scheduler) Next() (string, time.Time, bool) {
+	hosts := make([]string, 0, len(s.hosts))
+	for host := range s.hosts {
+		hosts = append(hosts, host)
+	}
+	if len(hosts) == 0 {
+		return "", time.Time{}, false
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		a, b := s.hosts[hosts[i]].NextRun, s.hosts[hosts[j]].NextRun
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return hosts[i] < hosts[j]
+	})
+	return hosts[0], s.hosts[hosts[0]].NextRun, true
+}
+
+// Done records that host ran at the given time and schedules its next run:
+// one interval later on success, or after an exponential backoff with
+// jitter when err is not nil.
+func (s *Scheduler) Done(host string, at time.Time, err error) {
+	hs := s.hosts[host]
+	hs.LastRun = at
+
+	if err == nil {
+		hs.Failures = 0
+		hs.NextRun = at.Add(s.config.Interval)
+		s.hosts[host] = hs
+		return
+	}
+
+	hs.Failures++
+	backoff := s.config.RetryBase
+	for i := 1; i < hs.Failures && backoff < s.config.Interval; i++ {
+		backoff *= 2
+	}
+	if backoff <= 0 || backoff > s.config.Interval {
+		backoff = s.config.Interval
+	}
+	half := backoff / 2 //nolint:mnd // equal jitter keeps at least half the backoff
+	hs.NextRun = at.Add(half + s.jitter(backoff-half))
+	s.hosts[host] = hs
+}
+
+// State returns a copy of the schedule for persisting.
+func (s *Scheduler) State() State {
+	state := State{Hosts: make(map[string]HostState, len(s.hosts))}
+	for host, hs := range s.hosts {
+		state.Hosts[host] = hs
+	}
+	return state
+}
+
+func (s *Scheduler) jitter(n time.Duration) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return s.random(n)
+}
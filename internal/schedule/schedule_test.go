@@ -0,0 +1,256 @@
+package schedule_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/schedule"
+)
+
+var errBackup = errors.New("backup failed")
+
+// offsets returns a jitter source handing out the given values in turn,
+// clamped below n.
+func offsets(values ...time.Duration) schedule.Option {
+	return schedule.WithRandom(func(n time.Duration) time.Duration {
+		if len(values) == 0 {
+			return 0
+		}
+		v := values[0]
+		values = values[1:]
+		return min(v, n-1)
+	})
+}
+
+func testConfig() schedule.Config {
+	return schedule.Config{Interval: time.Hour, Jitter: 10 * time.Minute, RetryBase: time.Minute}
+}
+
+func nextRuns(t *testing.T, s *schedule.Scheduler) map[string]time.Time {
+	t.Helper()
+
+	runs := map[string]time.Time{}
+	for host, hs := range s.State().Hosts {
+		runs[host] = hs.NextRun
+	}
+	return runs
+}
+
+func TestScheduler_ResumeAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)
+	hosts := []string{"r1", "r2", "r3"}
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	first := schedule.New(testConfig(), schedule.State{}, offsets(time.Minute, 4*time.Minute, 7*time.Minute))
+	first.Resume(hosts, start)
+	for range hosts {
+		host, at, ok := first.Next()
+		if !ok {
+			t.Fatal("Next() ok = false, want true")
+		}
+		first.Done(host, at, nil)
+	}
+	want := nextRuns(t, first)
+	if err := schedule.Save(path, first.State(), nil); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	t.Run("before next runs", func(t *testing.T) {
+		t.Parallel()
+
+		state, err := schedule.Load(path)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		restarted := schedule.New(testConfig(), state, offsets(9*time.Minute))
+		restarted.Resume(hosts, start.Add(30*time.Minute))
+
+		if got := nextRuns(t, restarted); !reflect.DeepEqual(got, want) {
+			t.Errorf("next runs after restart = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("after missed runs", func(t *testing.T) {
+		t.Parallel()
+
+		state, err := schedule.Load(path)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		restarted := schedule.New(testConfig(), state, offsets(9*time.Minute))
+		now := start.Add(5 * time.Hour)
+		restarted.Resume(hosts, now)
+
+		got := nextRuns(t, restarted)
+		wantSpread := map[string]time.Time{
+			"r1": now.Add(time.Minute),
+			"r2": now.Add(4 * time.Minute),
+			"r3": now.Add(7 * time.Minute),
+		}
+		if !reflect.DeepEqual(got, wantSpread) {
+			t.Errorf("next runs after restart = %v, want %v", got, wantSpread)
+		}
+	})
+}
+
+func TestScheduler_ResumeNewAndRemovedHosts(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)
+	state := schedule.State{Hosts: map[string]schedule.HostState{
+		"kept":    {NextRun: now.Add(20 * time.Minute), Offset: 2 * time.Minute},
+		"removed": {NextRun: now.Add(time.Minute), Offset: time.Minute},
+	}}
+
+	s := schedule.New(testConfig(), state, offsets(5*time.Minute))
+	s.Resume([]string{"kept", "added"}, now)
+
+	want := map[string]time.Time{
+		"kept":  now.Add(20 * time.Minute),
+		"added": now.Add(5 * time.Minute),
+	}
+	if got := nextRuns(t, s); !reflect.DeepEqual(got, want) {
+		t.Errorf("next runs = %v, want %v", got, want)
+	}
+	if host, _, _ := s.Next(); host != "added" {
+		t.Errorf("Next() host = %q, want %q", host, "added")
+	}
+}
+
+func TestScheduler_ResumeRedrawsOutOfRangeOffset(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)
+	state := schedule.State{Hosts: map[string]schedule.HostState{
+		"r1": {NextRun: now.Add(-time.Hour), Offset: 30 * time.Minute},
+	}}
+
+	s := schedule.New(testConfig(), state, offsets(3*time.Minute))
+	s.Resume([]string{"r1"}, now)
+
+	if got := s.State().Hosts["r1"]; got.Offset != 3*time.Minute || !got.NextRun.Equal(now.Add(3*time.Minute)) {
+		t.Errorf("host state = %+v, want offset 3m and next run 3m from now", got)
+	}
+}
+
+func TestScheduler_DoneBackoff(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		failures int
+		jitter   time.Duration
+		want     time.Duration
+	}{
+		{name: "first failure without jitter", failures: 1, want: 30 * time.Second},
+		{name: "first failure with full jitter", failures: 1, jitter: time.Hour, want: time.Minute - 1},
+		{name: "third failure", failures: 3, want: 2 * time.Minute},
+		{name: "capped at interval", failures: 10, want: 30 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			jitters := []time.Duration{0}
+			for range tt.failures {
+				jitters = append(jitters, tt.jitter)
+			}
+			s := schedule.New(testConfig(), schedule.State{}, offsets(jitters...))
+			s.Resume([]string{"r1"}, now)
+			for range tt.failures {
+				s.Done("r1", now, errBackup)
+			}
+
+			got := s.State().Hosts["r1"]
+			if got.Failures != tt.failures {
+				t.Errorf("failures = %d, want %d", got.Failures, tt.failures)
+			}
+			if delay := got.NextRun.Sub(now); delay != tt.want {
+				t.Errorf("retry delay = %v, want %v", delay, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduler_DoneSuccessResetsFailures(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)
+	s := schedule.New(testConfig(), schedule.State{}, offsets())
+	s.Resume([]string{"r1"}, now)
+	s.Done("r1", now, errBackup)
+	s.Done("r1", now.Add(time.Minute), nil)
+
+	got := s.State().Hosts["r1"]
+	want := schedule.HostState{LastRun: now.Add(time.Minute), NextRun: now.Add(time.Hour + time.Minute)}
+	if got != want {
+		t.Errorf("host state = %+v, want %+v", got, want)
+	}
+}
+
+func TestScheduler_NextEmpty(t *testing.T) {
+	t.Parallel()
+
+	if _, _, ok := schedule.New(testConfig(), schedule.State{}).Next(); ok {
+		t.Error("Next() ok = true, want false")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	state, err := schedule.Load(filepath.Join(dir, "missing.json"))
+	if err != nil {
+		t.Fatalf("Load() missing file error = %v, want nil", err)
+	}
+	if len(state.Hosts) != 0 {
+		t.Errorf("Load() missing file hosts = %v, want empty", state.Hosts)
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schedule.Load(corrupt); err == nil {
+		t.Error("Load() corrupt file error = nil, want error")
+	}
+}
+
+func TestSave_ReplacesState(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	now := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)
+
+	for _, next := range []time.Time{now, now.Add(time.Hour)} {
+		state := schedule.State{Hosts: map[string]schedule.HostState{"r1": {NextRun: next}}}
+		if err := schedule.Save(path, state, nil); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+
+	state, err := schedule.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := state.Hosts["r1"].NextRun; !got.Equal(now.Add(time.Hour)) {
+		t.Errorf("Load() next run = %v, want %v", got, now.Add(time.Hour))
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("state directory has %d entries, want only the state file", len(entries))
+	}
+}
@@ -0,0 +1,79 @@
+// Package srv discovers devices from DNS SRV records.
+package srv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ErrNoTargets is returned when an SRV name resolves to no usable targets.
+var ErrNoTargets = errors.New("no SRV targets")
+
+// Resolver looks up SRV records. *net.Resolver implements it.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Target is a device endpoint published in an SRV record.
+type Target struct {
+	Host string
+	Port int
+}
+
+// Lookup resolves the SRV name (e.g. "_mikrotik-ssh._tcp.example.com") and
+// returns every target it publishes. Targets are ordered by ascending
+// priority, then descending weight, so preferred endpoints come first, and
+// then by host and port so the order is the same on every lookup.
+// Duplicate targets and the "." target, meaning the service is unavailable,
+// are dropped.
+func Lookup(ctx context.Context, r Resolver, name string) ([]Target, error) {
+	_, records, err := r.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up SRV records for %s: %w", name, err)
+	}
+
+	sorted := make([]*net.SRV, 0, len(records))
+	for _, rec := range records {
+		if rec != nil {
+			sorted = append(sorted, rec)
+		}
+	}
+	// net.Resolver shuffles records of equal priority by weight, so break
+	// every tie explicitly.
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		switch {
+		case a.Priority != b.Priority:
+			return a.Priority < b.Priority
+		case a.Weight != b.Weight:
+			return a.Weight > b.Weight
+		case a.Target != b.Target:
+			return a.Target < b.Target
+		default:
+			return a.Port < b.Port
+		}
+	})
+
+	var targets []Target
+	seen := map[Target]bool{}
+	for _, rec := range sorted {
+		host := strings.TrimSuffix(rec.Target, ".")
+		if host == "" {
+			continue
+		}
+		t := Target{Host: host, Port: int(rec.Port)}
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoTargets, name)
+	}
+	return targets, nil
+}
@@ -0,0 +1,133 @@
+package srv_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/srv"
+)
+
+// fakeResolver is a Resolver returning fixed records for known names.
+type fakeResolver struct {
+	records map[string][]*net.SRV
+	err     error
+}
+
+func (f *fakeResolver) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if service != "" || proto != "" {
+		return "", nil, errors.New("expected a full SRV name")
+	}
+	if f.err != nil {
+		return "", nil, f.err
+	}
+	return name, f.records[name], nil
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	const name = "_mikrotik-ssh._tcp.example.com"
+	resolver := &fakeResolver{records: map[string][]*net.SRV{
+		name: {
+			{Target: "backup-rtr.example.com.", Port: 22, Priority: 20, Weight: 0},
+			{Target: "edge-b.example.com.", Port: 2222, Priority: 10, Weight: 10},
+			{Target: "edge-a.example.com.", Port: 22, Priority: 10, Weight: 60},
+			{Target: "edge-a.example.com.", Port: 22, Priority: 30, Weight: 0},
+			{Target: ".", Port: 0, Priority: 40, Weight: 0},
+		},
+	}}
+
+	got, err := srv.Lookup(context.Background(), resolver, name)
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+
+	want := []srv.Target{
+		{Host: "edge-a.example.com", Port: 22},
+		{Host: "edge-b.example.com", Port: 2222},
+		{Host: "backup-rtr.example.com", Port: 22},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lookup() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLookup_EqualWeights(t *testing.T) {
+	t.Parallel()
+
+	const name = "_mikrotik-ssh._tcp.example.com"
+	records := []*net.SRV{
+		{Target: "edge-c.example.com.", Port: 22, Priority: 10, Weight: 50},
+		{Target: "edge-a.example.com.", Port: 2222, Priority: 10, Weight: 50},
+		{Target: "edge-b.example.com.", Port: 22, Priority: 10, Weight: 50},
+		{Target: "edge-a.example.com.", Port: 22, Priority: 10, Weight: 50},
+	}
+	want := []srv.Target{
+		{Host: "edge-a.example.com", Port: 22},
+		{Host: "edge-a.example.com", Port: 2222},
+		{Host: "edge-b.example.com", Port: 22},
+		{Host: "edge-c.example.com", Port: 22},
+	}
+
+	// The records arrive in a different order on every lookup, as
+	// net.Resolver shuffles them.
+	for _, order := range [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {2, 0, 3, 1}} {
+		shuffled := make([]*net.SRV, 0, len(records))
+		for _, i := range order {
+			shuffled = append(shuffled, records[i])
+		}
+		resolver := &fakeResolver{records: map[string][]*net.SRV{name: shuffled}}
+
+		got, err := srv.Lookup(context.Background(), resolver, name)
+		if err != nil {
+			t.Fatalf("Lookup() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Lookup() with records in order %v = %+v, want %+v", order, got, want)
+		}
+	}
+}
+
+func TestLookup_Errors(t *testing.T) {
+	t.Parallel()
+
+	lookupErr := errors.New("SERVFAIL")
+
+	tests := []struct {
+		name     string
+		resolver *fakeResolver
+		wantErr  error
+	}{
+		{
+			name:     "resolver failure",
+			resolver: &fakeResolver{err: lookupErr},
+			wantErr:  lookupErr,
+		},
+		{
+			name:     "no records",
+			resolver: &fakeResolver{},
+			wantErr:  srv.ErrNoTargets,
+		},
+		{
+			name: "service unavailable",
+			resolver: &fakeResolver{records: map[string][]*net.SRV{
+				"_mikrotik-ssh._tcp.example.com": {{Target: "."}},
+			}},
+			wantErr: srv.ErrNoTargets,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := srv.Lookup(context.Background(), tt.resolver, "_mikrotik-ssh._tcp.example.com")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Lookup() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
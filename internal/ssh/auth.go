@@ -0,0 +1,269 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+// Authentication method names accepted by ParseAuthOrder.
+const (
+	AuthCertificate         = "certificate"
+	AuthAgent               = "agent"
+	AuthKey                 = "key"
+	AuthKeyboardInteractive = "keyboard-interactive"
+	AuthPassword            = "password"
+)
+
+var (
+	// ErrUnknownAuthMethod is returned for an unrecognized authentication method name.
+	ErrUnknownAuthMethod = errors.New("unknown authentication method")
+
+	// ErrNoAuthMethods is returned when the configuration provides no usable credentials.
+	ErrNoAuthMethods = errors.New("no authentication methods available")
+)
+
+// DefaultAuthOrder returns the order in which authentication methods are
+// tried when none is configured.
+func DefaultAuthOrder() []string {
+	return []string{AuthCertificate, AuthAgent, AuthKey, AuthKeyboardInteractive, AuthPassword}
+}
+
+// ParseAuthOrder parses a comma-separated list of authentication method names.
+func ParseAuthOrder(s string) ([]string, error) {
+	var order []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		switch name {
+		case AuthCertificate, AuthAgent, AuthKey, AuthKeyboardInteractive, AuthPassword:
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrUnknownAuthMethod, name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			order = append(order, name)
+		}
+	}
+	if len(order) == 0 {
+		return nil, fmt.Errorf("%w: empty authentication order", ErrNoAuthMethods)
+	}
+	return order, nil
+}
+
+// AuthRecorder tracks which authentication method was last used to answer
+// a server challenge. Once a handshake succeeds, Succeeded reports the
+// method that authenticated the connection.
+type AuthRecorder struct {
+	mu   sync.Mutex
+	last string
+}
+
+// Succeeded returns the label of the last method that responded to the server.
+func (r *AuthRecorder) Succeeded() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+func (r *AuthRecorder) record(label string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = label
+}
+
+// AuthMethods builds the ordered list of ssh.AuthMethod for config.
+//
+// The SSH protocol tries each method name at most once, so certificate,
+// agent and key authentication are combined into a single public key method
+// placed at the position of the first of them in the order; its signers are
+// offered in the configured order. Methods without credentials are skipped.
+// keyring may be nil when no SSH agent is available.
+func AuthMethods(config backup.Config, keyring agent.Agent, rec *AuthRecorder) ([]gossh.AuthMethod, error) {
+	order := config.AuthOrder
+	if len(order) == 0 {
+		order = DefaultAuthOrder()
+	}
+
+	keys, err := loadKeys(config.KeyFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []gossh.AuthMethod
+	var signers []gossh.Signer
+	publicKeyIndex := -1
+
+	for _, name := range order {
+		switch name {
+		case AuthCertificate:
+			if config.CertFile == "" {
+				continue
+			}
+			certSigner, err := certificateSigner(config.CertFile, keys)
+			if err != nil {
+				return nil, err
+			}
+			signers = append(signers, recordingSigner(certSigner, AuthCertificate, rec))
+		case AuthAgent:
+			if keyring == nil {
+				continue
+			}
+			agentSigners, err := keyring.Signers()
+			if err != nil {
+				return nil, fmt.Errorf("failed to list agent keys: %w", err)
+			}
+			for _, s := range agentSigners {
+				signers = append(signers, recordingSigner(s, AuthAgent, rec))
+			}
+		case AuthKey:
+			for _, k := range keys {
+				signers = append(signers, recordingSigner(k.signer, AuthKey+":"+k.path, rec))
+			}
+		case AuthKeyboardInteractive:
+			if config.Password == "" {
+				continue
+			}
+			methods = append(methods, keyboardInteractive(config.Password, rec))
+			continue
+		case AuthPassword:
+			if config.Password == "" {
+				continue
+			}
+			methods = append(methods, gossh.PasswordCallback(func() (string, error) {
+				rec.record(AuthPassword)
+				return config.Password, nil
+			}))
+			continue
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrUnknownAuthMethod, name)
+		}
+		if publicKeyIndex < 0 {
+			publicKeyIndex = len(methods)
+		}
+	}
+
+	if len(signers) > 0 {
+		publicKeys := gossh.PublicKeys(signers...)
+		methods = append(methods[:publicKeyIndex], append([]gossh.AuthMethod{publicKeys}, methods[publicKeyIndex:]...)...)
+	}
+
+	if len(methods) == 0 {
+		return nil, ErrNoAuthMethods
+	}
+
+	return methods, nil
+}
+
+type privateKey struct {
+	path   string
+	signer gossh.Signer
+}
+
+func loadKeys(paths []string) ([]privateKey, error) {
+	keys := make([]privateKey, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path) //nolint:gosec // path is provided by the operator
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file %s: %w", path, err)
+		}
+		signer, err := gossh.ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key file %s: %w", path, err)
+		}
+		keys = append(keys, privateKey{path: path, signer: signer})
+	}
+	return keys, nil
+}
+
+// certificateSigner pairs the certificate in path with the private key whose
+// public key it certifies.
+func certificateSigner(path string, keys []privateKey) (gossh.Signer, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path is provided by the operator
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate %s: %w", path, err)
+	}
+	pub, _, _, _, err := gossh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate %s: %w", path, err)
+	}
+	cert, ok := pub.(*gossh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse certificate %s: not an SSH certificate", path)
+	}
+
+	want := string(cert.Key.Marshal())
+	for _, k := range keys {
+		if string(k.signer.PublicKey().Marshal()) != want {
+			continue
+		}
+		signer, err := gossh.NewCertSigner(cert, k.signer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create certificate signer: %w", err)
+		}
+		return signer, nil
+	}
+
+	return nil, fmt.Errorf("no key file matches certificate %s", path)
+}
+
+func keyboardInteractive(password string, rec *AuthRecorder) gossh.AuthMethod {
+	return gossh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+		rec.record(AuthKeyboardInteractive)
+		answers := make([]string, len(questions))
+		for i := range answers {
+			answers[i] = password
+		}
+		return answers, nil
+	})
+}
+
+// recordingSigner wraps s so that rec notes label whenever s produces a
+// signature, which only happens once the server has accepted the key.
+func recordingSigner(s gossh.Signer, label string, rec *AuthRecorder) gossh.Signer {
+	if as, ok := s.(gossh.AlgorithmSigner); ok {
+		return &recordingAlgorithmSigner{AlgorithmSigner: as, label: label, rec: rec}
+	}
+	return &recordingPlainSigner{Signer: s, label: label, rec: rec}
+}
+
+type recordingAlgorithmSigner struct {
+	gossh.AlgorithmSigner
+	label string
+	rec   *AuthRecorder
+}
+
+func (s *recordingAlgorithmSigner) Sign(rand io.Reader, data []byte) (*gossh.Signature, error) {
+	s.rec.record(s.label)
+	return s.AlgorithmSigner.Sign(rand, data)
+}
+
+func (s *recordingAlgorithmSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*gossh.Signature, error) {
+	s.rec.record(s.label)
+	return s.AlgorithmSigner.SignWithAlgorithm(rand, data, algorithm)
+}
+
+type recordingPlainSigner struct {
+	gossh.Signer
+	label string
+	rec   *AuthRecorder
+}
+
+func (s *recordingPlainSigner) Sign(rand io.Reader, data []byte) (*gossh.Signature, error) {
+	s.rec.record(s.label)
+	return s.Signer.Sign(rand, data)
+}
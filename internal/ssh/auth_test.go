@@ -0,0 +1,327 @@
+package ssh_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/ssh"
+)
+
+const testPassword = "secret"
+
+// authFixture holds the credentials offered by the client under test and
+// the labels the test server uses to identify them.
+type authFixture struct {
+	config  backup.Config
+	keyring agent.Agent
+	labels  map[string]string
+}
+
+func newSigner(t *testing.T) (ed25519.PrivateKey, gossh.Signer) {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("NewSignerFromKey() error = %v", err)
+	}
+	return priv, signer
+}
+
+func writeKey(t *testing.T, dir, name string, priv ed25519.PrivateKey) string {
+	t.Helper()
+
+	block, err := gossh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatalf("MarshalPrivateKey() error = %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func newAuthFixture(t *testing.T) *authFixture {
+	t.Helper()
+
+	dir := t.TempDir()
+	certPriv, certKey := newSigner(t)
+	plainPriv, plainKey := newSigner(t)
+	agentPriv, agentKey := newSigner(t)
+	_, caKey := newSigner(t)
+
+	cert := &gossh.Certificate{
+		Key:             certKey.PublicKey(),
+		CertType:        gossh.UserCert,
+		ValidPrincipals: []string{"admin"},
+		ValidBefore:     gossh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, caKey); err != nil {
+		t.Fatalf("SignCert() error = %v", err)
+	}
+	certPath := filepath.Join(dir, "id_cert-cert.pub")
+	if err := os.WriteFile(certPath, gossh.MarshalAuthorizedKey(cert), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: agentPriv}); err != nil {
+		t.Fatalf("keyring.Add() error = %v", err)
+	}
+
+	return &authFixture{
+		config: backup.Config{
+			Username: "admin",
+			Password: testPassword,
+			KeyFiles: []string{writeKey(t, dir, "id_cert", certPriv), writeKey(t, dir, "id_plain", plainPriv)},
+			CertFile: certPath,
+		},
+		keyring: keyring,
+		labels: map[string]string{
+			string(cert.Marshal()):                 ssh.AuthCertificate,
+			string(agentKey.PublicKey().Marshal()): ssh.AuthAgent,
+			string(certKey.PublicKey().Marshal()):  ssh.AuthKey,
+			string(plainKey.PublicKey().Marshal()): ssh.AuthKey,
+		},
+	}
+}
+
+// authServer is a test SSH server that records authentication attempts
+// in the order they arrive.
+type authServer struct {
+	mu       sync.Mutex
+	attempts []string
+	hostKey  gossh.PublicKey
+	addr     string
+}
+
+func (s *authServer) record(label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = append(s.attempts, label)
+}
+
+func (s *authServer) Attempts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.attempts...)
+}
+
+func startAuthServer(t *testing.T, labels map[string]string, accept string) *authServer {
+	t.Helper()
+
+	_, hostKey := newSigner(t)
+	srv := &authServer{hostKey: hostKey.PublicKey()}
+	errRejected := errors.New("rejected")
+
+	config := &gossh.ServerConfig{
+		MaxAuthTries: -1,
+		PublicKeyCallback: func(_ gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			label := labels[string(key.Marshal())]
+			srv.record(label)
+			if label == accept {
+				return &gossh.Permissions{}, nil
+			}
+			return nil, errRejected
+		},
+		KeyboardInteractiveCallback: func(_ gossh.ConnMetadata, challenge gossh.KeyboardInteractiveChallenge) (*gossh.Permissions, error) {
+			srv.record(ssh.AuthKeyboardInteractive)
+			answers, err := challenge("", "", []string{"Password: "}, []bool{false})
+			if err == nil && accept == ssh.AuthKeyboardInteractive && len(answers) == 1 && answers[0] == testPassword {
+				return &gossh.Permissions{}, nil
+			}
+			return nil, errRejected
+		},
+		PasswordCallback: func(_ gossh.ConnMetadata, password []byte) (*gossh.Permissions, error) {
+			srv.record(ssh.AuthPassword)
+			if accept == ssh.AuthPassword && string(password) == testPassword {
+				return &gossh.Permissions{}, nil
+			}
+			return nil, errRejected
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	srv.addr = listener.Addr().String()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		sshConn, chans, reqs, err := gossh.NewServerConn(conn, config)
+		if err != nil {
+			return
+		}
+		defer func() { _ = sshConn.Close() }()
+		go gossh.DiscardRequests(reqs)
+		for ch := range chans {
+			_ = ch.Reject(gossh.Prohibited, "no channels")
+		}
+	}()
+
+	return srv
+}
+
+func TestAuthMethods_Order(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		order         []string
+		accept        string
+		wantAttempts  []string
+		wantSucceeded string
+	}{
+		{
+			name:   "default order falls back to password",
+			accept: ssh.AuthPassword,
+			wantAttempts: []string{
+				ssh.AuthCertificate, ssh.AuthAgent, ssh.AuthKey, ssh.AuthKey,
+				ssh.AuthKeyboardInteractive, ssh.AuthPassword,
+			},
+			wantSucceeded: ssh.AuthPassword,
+		},
+		{
+			name:          "stops at first success",
+			accept:        ssh.AuthCertificate,
+			wantAttempts:  []string{ssh.AuthCertificate},
+			wantSucceeded: ssh.AuthCertificate,
+		},
+		{
+			name:          "agent after failed certificate",
+			accept:        ssh.AuthAgent,
+			wantAttempts:  []string{ssh.AuthCertificate, ssh.AuthAgent},
+			wantSucceeded: ssh.AuthAgent,
+		},
+		{
+			name:          "custom order",
+			order:         []string{ssh.AuthPassword, ssh.AuthKeyboardInteractive},
+			accept:        ssh.AuthKeyboardInteractive,
+			wantAttempts:  []string{ssh.AuthPassword, ssh.AuthKeyboardInteractive},
+			wantSucceeded: ssh.AuthKeyboardInteractive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fixture := newAuthFixture(t)
+			fixture.config.AuthOrder = tt.order
+			srv := startAuthServer(t, fixture.labels, tt.accept)
+
+			rec := &ssh.AuthRecorder{}
+			methods, err := ssh.AuthMethods(fixture.config, fixture.keyring, rec)
+			if err != nil {
+				t.Fatalf("AuthMethods() error = %v", err)
+			}
+
+			client, err := gossh.Dial("tcp", srv.addr, &gossh.ClientConfig{
+				User:            fixture.config.Username,
+				Auth:            methods,
+				HostKeyCallback: gossh.FixedHostKey(srv.hostKey),
+			})
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			_ = client.Close()
+
+			if got := srv.Attempts(); !reflect.DeepEqual(got, tt.wantAttempts) {
+				t.Errorf("attempts = %v, want %v", got, tt.wantAttempts)
+			}
+			if got := rec.Succeeded(); got != tt.wantSucceeded {
+				t.Errorf("Succeeded() = %q, want %q", got, tt.wantSucceeded)
+			}
+		})
+	}
+}
+
+func TestAuthMethods_KeyLabelIncludesPath(t *testing.T) {
+	t.Parallel()
+
+	fixture := newAuthFixture(t)
+	fixture.config.AuthOrder = []string{ssh.AuthKey}
+	srv := startAuthServer(t, fixture.labels, ssh.AuthKey)
+
+	rec := &ssh.AuthRecorder{}
+	methods, err := ssh.AuthMethods(fixture.config, nil, rec)
+	if err != nil {
+		t.Fatalf("AuthMethods() error = %v", err)
+	}
+
+	client, err := gossh.Dial("tcp", srv.addr, &gossh.ClientConfig{
+		User:            fixture.config.Username,
+		Auth:            methods,
+		HostKeyCallback: gossh.FixedHostKey(srv.hostKey),
+	})
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	_ = client.Close()
+
+	if want := ssh.AuthKey + ":" + fixture.config.KeyFiles[0]; rec.Succeeded() != want {
+		t.Errorf("Succeeded() = %q, want %q", rec.Succeeded(), want)
+	}
+}
+
+func TestAuthMethods_NoCredentials(t *testing.T) {
+	t.Parallel()
+
+	_, err := ssh.AuthMethods(backup.Config{Username: "admin"}, nil, &ssh.AuthRecorder{})
+	if !errors.Is(err, ssh.ErrNoAuthMethods) {
+		t.Errorf("AuthMethods() error = %v, want ErrNoAuthMethods", err)
+	}
+}
+
+func TestParseAuthOrder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr error
+	}{
+		{name: "single", input: "password", want: []string{ssh.AuthPassword}},
+		{name: "trims and dedupes", input: " key, password ,key", want: []string{ssh.AuthKey, ssh.AuthPassword}},
+		{name: "unknown", input: "key,telepathy", wantErr: ssh.ErrUnknownAuthMethod},
+		{name: "empty", input: " , ", wantErr: ssh.ErrNoAuthMethods},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ssh.ParseAuthOrder(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseAuthOrder(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAuthOrder(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+// Package ssh implements backup.SSHClient on top of golang.org/x/crypto/ssh.
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// ErrNotConnected is returned when a command is issued before Connect.
+var ErrNotConnected = errors.New("not connected")
+
+// Client is an SSH client for RouterOS devices.
+type Client struct {
+	hostKeyCallback gossh.HostKeyCallback
+	timeout         time.Duration
+
+	client        *gossh.Client
+	agentConn     net.Conn
+	authMethod    string
+	maxOutputSize int64
+}
+
+// NewClient creates a new SSH client that verifies host keys with hostKeyCallback.
+func NewClient(hostKeyCallback gossh.HostKeyCallback) *Client {
+	return &Client{
+		hostKeyCallback: hostKeyCallback,
+		timeout:         defaultTimeout,
+	}
+}
+
+// Connect establishes an SSH connection to the device described by config.
+func (c *Client) Connect(ctx context.Context, config backup.Config) error {
+	keyring := c.dialAgent(ctx)
+
+	rec := &AuthRecorder{}
+	methods, err := AuthMethods(config, keyring, rec)
+	if err != nil {
+		c.closeAgent()
+		return fmt.Errorf("failed to prepare authentication: %w", err)
+	}
+
+	clientConfig := &gossh.ClientConfig{
+		User:            config.Username,
+		Auth:            methods,
+		HostKeyCallback: c.hostKeyCallback,
+		Timeout:         c.timeout,
+	}
+
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	dialer := &net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		c.closeAgent()
+		return fmt.Errorf("failed to dial %s: %w", addr, err)
+	}
+
+	client, err := handshake(ctx, conn, addr, clientConfig)
+	if err != nil {
+		c.closeAgent()
+		return err
+	}
+
+	c.client = client
+	c.authMethod = rec.Succeeded()
+	c.maxOutputSize = config.MaxOutputSize
+	return nil
+}
+
+// handshake performs the SSH handshake on conn, aborting when ctx is done.
+func handshake(ctx context.Context, conn net.Conn, addr string, config *gossh.ClientConfig) (*gossh.Client, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	sshConn, chans, reqs, err := gossh.NewClientConn(conn, addr, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to establish SSH session with %s: %w", addr, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	return gossh.NewClient(sshConn, chans, reqs), nil
+}
+
+// ExecuteCommand runs cmd on the device and returns its standard output.
+// Standard output or error beyond backup.Config.MaxOutputSize aborts the
+// command with backup.ErrOutputTooLarge.
+func (c *Client) ExecuteCommand(ctx context.Context, cmd string) (string, error) {
+	if c.client == nil {
+		return "", ErrNotConnected
+	}
+
+	session, err := c.client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to open session: %w", err)
+	}
+	defer func() { _ = session.Close() }()
+
+	stop := context.AfterFunc(ctx, func() { _ = session.Close() })
+	defer stop()
+
+	var stdout, stderr bytes.Buffer
+	pipe, err := session.StdoutPipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to read command output: %w", err)
+	}
+	errPipe, err := session.StderrPipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to read command output: %w", err)
+	}
+
+	if err := session.Start(cmd); err != nil {
+		return "", fmt.Errorf("command %q failed: %w", cmd, err)
+	}
+
+	// Both streams are read at once, as they share the channel window, and
+	// both are capped so neither can exhaust memory.
+	stderrDone := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(&backup.LimitedWriter{W: &stderr, N: c.maxOutputSize}, errPipe)
+		if err != nil {
+			_ = session.Close()
+		}
+		stderrDone <- err
+	}()
+	_, err = io.Copy(&backup.LimitedWriter{W: &stdout, N: c.maxOutputSize}, pipe)
+	if err != nil {
+		// Stop the device from sending more; the partial output is dropped.
+		_ = session.Close()
+	}
+	if stderrErr := <-stderrDone; err == nil {
+		err = stderrErr
+	}
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("command %q canceled: %w", cmd, ctxErr)
+		}
+		return "", fmt.Errorf("command %q aborted: %w", cmd, err)
+	}
+
+	if err := session.Wait(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("command %q canceled: %w", cmd, ctxErr)
+		}
+		if stderr.Len() > 0 {
+			return "", fmt.Errorf("command %q failed: %w: %s", cmd, err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return "", fmt.Errorf("command %q failed: %w", cmd, err)
+	}
+
+	return stdout.String(), nil
+}
+
+// AuthMethod returns the authentication method that succeeded for the
+// current connection.
+func (c *Client) AuthMethod() string {
+	return c.authMethod
+}
+
+// Close terminates the SSH connection.
+func (c *Client) Close() error {
+	c.closeAgent()
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	if err != nil {
+		return fmt.Errorf("failed to close connection: %w", err)
+	}
+	return nil
+}
+
+// dialAgent connects to the SSH agent advertised by SSH_AUTH_SOCK, if any.
+func (c *Client) dialAgent(ctx context.Context) agent.Agent {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", sock)
+	if err != nil {
+		return nil
+	}
+	c.agentConn = conn
+	return agent.NewClient(conn)
+}
+
+func (c *Client) closeAgent() {
+	if c.agentConn != nil {
+		_ = c.agentConn.Close()
+		c.agentConn = nil
+	}
+}
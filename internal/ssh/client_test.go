@@ -0,0 +1,166 @@
+package ssh_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/backup"
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/ssh"
+)
+
+// startStreamServer starts a test SSH server accepting testPassword whose
+// exec requests send chunk count times, to standard error if toStderr is
+// set, and exit. A negative count streams forever, like a device stuck in a
+// loop.
+func startStreamServer(t *testing.T, chunk string, count int, toStderr bool) (string, gossh.PublicKey) {
+	t.Helper()
+
+	_, hostKey := newSigner(t)
+	config := &gossh.ServerConfig{
+		PasswordCallback: func(_ gossh.ConnMetadata, password []byte) (*gossh.Permissions, error) {
+			if string(password) != testPassword {
+				return nil, errors.New("rejected")
+			}
+			return &gossh.Permissions{}, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		sshConn, chans, reqs, err := gossh.NewServerConn(conn, config)
+		if err != nil {
+			return
+		}
+		defer func() { _ = sshConn.Close() }()
+		go gossh.DiscardRequests(reqs)
+
+		for newChannel := range chans {
+			channel, requests, err := newChannel.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				for req := range requests {
+					_ = req.Reply(req.Type == "exec", nil)
+					if req.Type != "exec" {
+						continue
+					}
+					go func() {
+						var w io.Writer = channel
+						if toStderr {
+							w = channel.Stderr()
+						}
+						for i := 0; count < 0 || i < count; i++ {
+							if _, err := w.Write([]byte(chunk)); err != nil {
+								return
+							}
+						}
+						_, _ = channel.SendRequest("exit-status", false, gossh.Marshal(struct{ Status uint32 }{}))
+						_ = channel.Close()
+					}()
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), hostKey.PublicKey()
+}
+
+// connectStreamServer connects a client limited to maxOutputSize bytes to
+// a server started with startStreamServer.
+func connectStreamServer(ctx context.Context, t *testing.T, chunk string, count int, toStderr bool, maxOutputSize int64) *ssh.Client {
+	t.Helper()
+
+	addr, hostKey := startStreamServer(t, chunk, count, toStderr)
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(portStr)
+
+	client := ssh.NewClient(gossh.FixedHostKey(hostKey))
+	config := backup.Config{
+		Host:          host,
+		Port:          port,
+		Username:      "admin",
+		Password:      testPassword,
+		AuthOrder:     []string{ssh.AuthPassword},
+		MaxOutputSize: maxOutputSize,
+	}
+	if err := client.Connect(ctx, config); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestClient_ExecuteCommand(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	chunk := "/ip address add\n"
+	client := connectStreamServer(ctx, t, chunk, 100, false, 64<<10)
+
+	output, err := client.ExecuteCommand(ctx, "/export")
+	if err != nil {
+		t.Fatalf("ExecuteCommand() error = %v", err)
+	}
+	if want := strings.Repeat(chunk, 100); output != want {
+		t.Errorf("ExecuteCommand() returned %d bytes, want %d", len(output), len(want))
+	}
+}
+
+func TestClient_ExecuteCommand_MaxOutputSize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		toStderr bool
+	}{
+		{name: "standard output"},
+		{name: "standard error", toStderr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			client := connectStreamServer(ctx, t, strings.Repeat("/ip address add\n", 256), -1, tt.toStderr, 64<<10)
+
+			output, err := client.ExecuteCommand(ctx, "/export")
+			if !errors.Is(err, backup.ErrOutputTooLarge) {
+				t.Fatalf("ExecuteCommand() error = %v, want ErrOutputTooLarge", err)
+			}
+			if output != "" {
+				t.Errorf("ExecuteCommand() returned %d bytes of partial output, want none", len(output))
+			}
+			if ctx.Err() != nil {
+				t.Error("ExecuteCommand() only returned once the context expired")
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package ssh
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/term"
+)
+
+var (
+	// ErrHostKeyRejected is returned when the operator declines an unknown host key.
+	ErrHostKeyRejected = errors.New("host key rejected")
+
+	// ErrNotInteractive is returned when confirmation is required but no
+	// terminal is available to ask on.
+	ErrNotInteractive = errors.New("cannot confirm host key: not running in a terminal")
+)
+
+// Prompt asks the operator whether to trust the host key with the given
+// SHA256 fingerprint.
+type Prompt func(hostname, fingerprint string) (bool, error)
+
+// NewTerminalPrompt returns a Prompt that asks on out and reads the answer
+// from in. It fails with ErrNotInteractive instead of prompting when in is
+// not a terminal, e.g. /dev/null under cron or systemd.
+func NewTerminalPrompt(in *os.File, out io.Writer) Prompt {
+	return func(hostname, fingerprint string) (bool, error) {
+		if !term.IsTerminal(int(in.Fd())) { //nolint:gosec // file descriptors fit in an int
+			return false, ErrNotInteractive
+		}
+
+		_, _ = fmt.Fprintf(out, "The authenticity of host %s can't be established.\n", hostname)
+		_, _ = fmt.Fprintf(out, "Key fingerprint is %s.\n", fingerprint)
+		_, _ = fmt.Fprint(out, "Are you sure you want to continue connecting (yes/no)? ")
+
+		answer, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return false, fmt.Errorf("failed to read answer: %w", err)
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "yes", "y":
+			return true, nil
+		default:
+			return false, nil
+		}
+	}
+}
+
+// ConfirmingHostKeyCallback verifies host keys against the known_hosts file
+// at path. Unknown hosts are shown to prompt and, once accepted, appended to
+// the file. A key that differs from a known one is always refused.
+func ConfirmingHostKeyCallback(path string, prompt Prompt) (gossh.HostKeyCallback, error) {
+	if err := ensureKnownHosts(path); err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Reload for every host so keys accepted earlier in the run count.
+		verify, err := knownhosts.New(path)
+		if err != nil {
+			return fmt.Errorf("failed to load known hosts: %w", err)
+		}
+
+		err = verify(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) || len(keyErr.Want) > 0 {
+			return fmt.Errorf("host key verification failed for %s: %w", hostname, err)
+		}
+
+		ok, err := prompt(hostname, gossh.FingerprintSHA256(key))
+		if err != nil {
+			return fmt.Errorf("unknown host %s: %w", hostname, err)
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrHostKeyRejected, hostname)
+		}
+
+		return appendKnownHost(path, hostname, key)
+	}, nil
+}
+
+func ensureKnownHosts(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create known hosts directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0o600) //nolint:gosec // path is provided by the operator
+	if err != nil {
+		return fmt.Errorf("failed to open known hosts: %w", err)
+	}
+	_ = f.Close()
+	return nil
+}
+
+func appendKnownHost(path, hostname string, key gossh.PublicKey) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600) //nolint:gosec // path is provided by the operator
+	if err != nil {
+		return fmt.Errorf("failed to open known hosts: %w", err)
+	}
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to record host key: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to record host key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package ssh_test
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+
+	"github.com/mountain-reverie/mikrotik-configuration-backup/internal/ssh"
+)
+
+func testRemote() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 22}
+}
+
+// countingPrompt returns a Prompt answering with answer and counting calls.
+func countingPrompt(answer bool, err error, calls *int, fingerprints *[]string) ssh.Prompt {
+	return func(_, fingerprint string) (bool, error) {
+		*calls++
+		*fingerprints = append(*fingerprints, fingerprint)
+		return answer, err
+	}
+}
+
+func TestConfirmingHostKeyCallback_Accept(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+	_, hostKey := newSigner(t)
+
+	calls := 0
+	var fingerprints []string
+	callback, err := ssh.ConfirmingHostKeyCallback(path, countingPrompt(true, nil, &calls, &fingerprints))
+	if err != nil {
+		t.Fatalf("ConfirmingHostKeyCallback() error = %v", err)
+	}
+
+	if err := callback("10.0.0.1:22", testRemote(), hostKey.PublicKey()); err != nil {
+		t.Fatalf("callback() error = %v", err)
+	}
+	if err := callback("10.0.0.1:22", testRemote(), hostKey.PublicKey()); err != nil {
+		t.Fatalf("callback() for accepted host error = %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("prompt called %d times, want 1", calls)
+	}
+	if want := gossh.FingerprintSHA256(hostKey.PublicKey()); fingerprints[0] != want {
+		t.Errorf("prompt fingerprint = %q, want %q", fingerprints[0], want)
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // test file
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !strings.HasPrefix(string(data), "10.0.0.1 ssh-ed25519 ") {
+		t.Errorf("known_hosts = %q, want entry for 10.0.0.1", data)
+	}
+}
+
+func TestConfirmingHostKeyCallback_Reject(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	_, hostKey := newSigner(t)
+
+	calls := 0
+	var fingerprints []string
+	callback, err := ssh.ConfirmingHostKeyCallback(path, countingPrompt(false, nil, &calls, &fingerprints))
+	if err != nil {
+		t.Fatalf("ConfirmingHostKeyCallback() error = %v", err)
+	}
+
+	err = callback("10.0.0.1:22", testRemote(), hostKey.PublicKey())
+	if !errors.Is(err, ssh.ErrHostKeyRejected) {
+		t.Fatalf("callback() error = %v, want ErrHostKeyRejected", err)
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // test file
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if len(data) != 0 {
+		t.Errorf("known_hosts = %q, want empty after rejection", data)
+	}
+}
+
+func TestConfirmingHostKeyCallback_ChangedKeyNeverPrompts(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	_, oldKey := newSigner(t)
+	_, newKey := newSigner(t)
+
+	calls := 0
+	var fingerprints []string
+	callback, err := ssh.ConfirmingHostKeyCallback(path, countingPrompt(true, nil, &calls, &fingerprints))
+	if err != nil {
+		t.Fatalf("ConfirmingHostKeyCallback() error = %v", err)
+	}
+	if err := callback("10.0.0.1:22", testRemote(), oldKey.PublicKey()); err != nil {
+		t.Fatalf("callback() error = %v", err)
+	}
+
+	if err := callback("10.0.0.1:22", testRemote(), newKey.PublicKey()); err == nil {
+		t.Fatal("callback() for changed key error = nil, want error")
+	}
+	if calls != 1 {
+		t.Errorf("prompt called %d times, want 1", calls)
+	}
+}
+
+func TestConfirmingHostKeyCallback_NotInteractive(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		stdin func(t *testing.T) *os.File
+	}{
+		{
+			name: "regular file",
+			stdin: func(t *testing.T) *os.File {
+				t.Helper()
+				f, err := os.Create(filepath.Join(t.TempDir(), "stdin"))
+				if err != nil {
+					t.Fatalf("Create() error = %v", err)
+				}
+				if _, err := f.WriteString("yes\n"); err != nil {
+					t.Fatalf("WriteString() error = %v", err)
+				}
+				return f
+			},
+		},
+		{
+			// cron and systemd attach stdin to /dev/null, a character device.
+			name: "null device",
+			stdin: func(t *testing.T) *os.File {
+				t.Helper()
+				f, err := os.Open(os.DevNull)
+				if err != nil {
+					t.Fatalf("Open() error = %v", err)
+				}
+				return f
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stdin := tt.stdin(t)
+			t.Cleanup(func() { _ = stdin.Close() })
+
+			out := &bytes.Buffer{}
+			path := filepath.Join(t.TempDir(), "known_hosts")
+			callback, err := ssh.ConfirmingHostKeyCallback(path, ssh.NewTerminalPrompt(stdin, out))
+			if err != nil {
+				t.Fatalf("ConfirmingHostKeyCallback() error = %v", err)
+			}
+
+			_, hostKey := newSigner(t)
+			err = callback("10.0.0.1:22", testRemote(), hostKey.PublicKey())
+			if !errors.Is(err, ssh.ErrNotInteractive) {
+				t.Fatalf("callback() error = %v, want ErrNotInteractive", err)
+			}
+			if out.Len() != 0 {
+				t.Errorf("prompt printed %q, want nothing without a terminal", out.String())
+			}
+		})
+	}
+}